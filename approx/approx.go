@@ -29,6 +29,20 @@ func ApproxFind(pattern string, text string, maxE int, op Options) ([]Match, err
 	return matches, nil
 }
 
+// ApproxFindAlignments finds 'pattern' in 'text' like ApproxFind, but also returns
+// the edits of each alignment. Set op.MaxCoOptimal to see every equally good
+// alignment, for instance the possible placements of an indel in a repeat.
+func ApproxFindAlignments(pattern string, text string, maxE int, op Options) ([]Alignment, error) {
+	// Check for empty strings first
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern to search empty")
+	} else if text == "" {
+		return nil, fmt.Errorf("text to search is empty")
+	}
+	c := LevenContext{}
+	return c.ApproxLevenAlignments(pattern, text, maxE, op)
+}
+
 // This version makes use of the pigeon hole principle, which is the idea that
 // if I am going to have x number of mutations, then if I split my pattern into
 // x + 1 regions, I will have at least one region that will match exaclty to the
@@ -0,0 +1,315 @@
+package approx
+
+import (
+	"fmt"
+)
+
+// bitapElem is one position of a Bitap pattern
+type bitapElem struct {
+	literal  rune
+	any      bool        // '.' matches any rune
+	class    []runeRange // a character class like [ACG]
+	negate   bool        // the class was written [^...]
+	optional bool        // followed by '?', so it may be skipped for free
+}
+
+// runeRange is an inclusive range of runes in a character class
+type runeRange struct {
+	lo, hi rune
+}
+
+// matches reports whether the element accepts r. A class accepts r when
+// op.Matches accepts it for any rune of the class.
+func (e bitapElem) matches(r rune, op Options) bool {
+	if e.any {
+		return true
+	}
+	if e.class == nil {
+		return op.Matches(e.literal, r)
+	}
+	in := false
+	for _, rr := range e.class {
+		if rr.lo <= r && r <= rr.hi {
+			in = true
+			break
+		}
+	}
+	// Only try the runes of the class one at a time when r isn't one of them
+	for _, rr := range e.class {
+		for c := rr.lo; !in && c <= rr.hi; c++ {
+			in = op.Matches(c, r)
+		}
+	}
+	return in != e.negate
+}
+
+// bitapBlock is a run of consecutive optional elements, as a mask of their bits
+// along with the bit of the element before them (0 if the run starts the pattern)
+type bitapBlock struct {
+	mask   uint64
+	before uint64
+}
+
+// Bitap is a compiled agrep-style pattern, searched for with the Wu-Manber
+// extension of the bitap (shift-and) algorithm. Patterns are limited to 64
+// positions.
+type Bitap struct {
+	elems  []bitapElem
+	blocks []bitapBlock
+	// reverse is the pattern backwards, used to find where a match starts
+	reverse *Bitap
+}
+
+// BitapFind compiles pattern with CompileBitap and finds it in text with up to maxE
+// insertions, deletions, and substitutions.
+func BitapFind(pattern string, text string, maxE int, op Options) ([]Match, error) {
+	b, err := CompileBitap(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return b.Find(text, maxE, op)
+}
+
+// CompileBitap parses an agrep-style pattern. Besides literal runes, it
+// understands the following, with literals and the runes of classes compared
+// with Options.Matches:
+//
+//	.        any rune
+//	[ACG]    any of the runes in the class, ranges like [a-z] are allowed
+//	[^ACG]   any rune not in the class
+//	x?       the previous rune, class, or '.' is optional
+//	\x       x taken literally
+//
+// For example "ACGT[AG]..NNGG?" is a barcode with a degenerate purine, two wildcard
+// positions, and an optional trailing G.
+func CompileBitap(pattern string) (*Bitap, error) {
+	elems, err := parseBitap([]rune(pattern))
+	if err != nil {
+		return nil, err
+	}
+	if len(elems) == 0 {
+		return nil, fmt.Errorf("pattern to search empty")
+	} else if len(elems) > 64 {
+		return nil, fmt.Errorf("pattern has %d positions, bitap supports at most 64", len(elems))
+	}
+	reversed := make([]bitapElem, len(elems))
+	for i, e := range elems {
+		reversed[len(elems)-1-i] = e
+	}
+	b := newBitap(elems)
+	b.reverse = newBitap(reversed)
+	return b, nil
+}
+
+// newBitap finds the optional blocks of the elements
+func newBitap(elems []bitapElem) *Bitap {
+	b := &Bitap{elems: elems}
+	for i := 0; i < len(elems); i++ {
+		if !elems[i].optional {
+			continue
+		}
+		block := bitapBlock{}
+		if i > 0 {
+			block.before = 1 << uint(i-1)
+		}
+		for ; i < len(elems) && elems[i].optional; i++ {
+			block.mask |= 1 << uint(i)
+		}
+		b.blocks = append(b.blocks, block)
+	}
+	return b
+}
+
+// parseBitap splits the pattern into its elements
+func parseBitap(p []rune) ([]bitapElem, error) {
+	elems := []bitapElem{}
+	for i := 0; i < len(p); i++ {
+		switch p[i] {
+		case '.':
+			elems = append(elems, bitapElem{any: true})
+		case '?':
+			if len(elems) == 0 || elems[len(elems)-1].optional {
+				return nil, fmt.Errorf("'?' at %d has nothing to make optional", i)
+			}
+			elems[len(elems)-1].optional = true
+		case '\\':
+			if i+1 == len(p) {
+				return nil, fmt.Errorf("trailing '\\' in pattern")
+			}
+			i++
+			elems = append(elems, bitapElem{literal: p[i]})
+		case '[':
+			elem, end, err := parseBitapClass(p, i)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, elem)
+			i = end
+		default:
+			elems = append(elems, bitapElem{literal: p[i]})
+		}
+	}
+	return elems, nil
+}
+
+// parseBitapClass parses the class starting at p[start] == '[' and returns it
+// along with the index of its closing ']'
+func parseBitapClass(p []rune, start int) (bitapElem, int, error) {
+	elem := bitapElem{class: []runeRange{}}
+	i := start + 1
+	if i < len(p) && p[i] == '^' {
+		elem.negate = true
+		i++
+	}
+	for ; i < len(p) && p[i] != ']'; i++ {
+		lo := p[i]
+		if lo == '\\' && i+1 < len(p) {
+			i++
+			lo = p[i]
+		}
+		hi := lo
+		if i+2 < len(p) && p[i+1] == '-' && p[i+2] != ']' {
+			hi = p[i+2]
+			i += 2
+			if hi < lo {
+				return elem, 0, fmt.Errorf("bad range %c-%c in class at %d", lo, hi, start)
+			}
+		}
+		elem.class = append(elem.class, runeRange{lo, hi})
+	}
+	if i == len(p) {
+		return elem, 0, fmt.Errorf("unclosed class at %d", start)
+	} else if len(elem.class) == 0 {
+		return elem, 0, fmt.Errorf("empty class at %d", start)
+	}
+	return elem, i, nil
+}
+
+// closure lets every active state skip over the optional elements after it.
+// fromStart says whether the empty prefix is active, which enters any optional
+// block at the start of the pattern.
+func (b *Bitap) closure(d uint64, fromStart bool) uint64 {
+	for _, block := range b.blocks {
+		entry := d & (block.mask | block.before)
+		if block.before == 0 && fromStart {
+			entry |= block.mask & -block.mask
+		}
+		if entry == 0 {
+			continue
+		}
+		// Every block bit from the lowest entry on is reachable
+		low := entry & -entry
+		d |= block.mask &^ (low - 1)
+	}
+	return d
+}
+
+// masks builds the bit mask of the elements that accept each text rune
+func (b *Bitap) masks(op Options) func(rune) uint64 {
+	cache := make(map[rune]uint64)
+	return func(r rune) uint64 {
+		if mask, ok := cache[r]; ok {
+			return mask
+		}
+		var mask uint64
+		for i, e := range b.elems {
+			if e.matches(r, op) {
+				mask |= 1 << uint(i)
+			}
+		}
+		cache[r] = mask
+		return mask
+	}
+}
+
+// scan runs the Wu-Manber automaton over text, with state[d] holding the pattern
+// prefixes that match the text so far with d errors. With anchored set, the match
+// must begin at text[0]. For each position, found is called with the lowest number
+// of errors the whole pattern matches with, and scanning stops if it returns false.
+func (b *Bitap) scan(text []rune, maxE int, anchored bool, op Options, found func(j int, dist int) bool) {
+	final := uint64(1) << uint(len(b.elems)-1)
+	mask := b.masks(op)
+	state := make([]uint64, maxE+1)
+	// Before anything is read, the leading optional elements can be skipped,
+	// and each level can delete one more element than the level before it
+	state[0] = b.closure(0, true)
+	for d := 1; d <= maxE; d++ {
+		state[d] = b.closure(state[d-1]<<1|1, true)
+	}
+	report := func(j int) bool {
+		for d := range state {
+			if state[d]&final != 0 {
+				return found(j, d)
+			}
+		}
+		return true
+	}
+	if !report(0) {
+		return
+	}
+	// started reports whether the match can still be at its very beginning at
+	// level d once consumed runes have been read. Anchored matches can only start
+	// late by inserting the runes they skip, one error each.
+	started := func(d int, consumed int) uint64 {
+		if anchored && d < consumed {
+			return 0
+		}
+		return 1
+	}
+	for j, r := range text {
+		m := mask(r)
+		prev := state[0]
+		state[0] = b.closure((state[0]<<1|started(0, j))&m, started(0, j+1) == 1)
+		for d := 1; d <= maxE; d++ {
+			cur := state[d]
+			state[d] = (cur<<1|started(d, j))&m | // match
+				prev | // insertion of a text rune
+				prev<<1 | started(d-1, j) | // substitution
+				state[d-1]<<1 | started(d-1, j+1) // deletion of a pattern element
+			state[d] = b.closure(state[d], started(d, j+1) == 1)
+			prev = cur
+		}
+		if !report(j + 1) {
+			return
+		}
+	}
+}
+
+// Find returns every end position in text where the pattern matches with up to maxE
+// errors, with the lowest number of errors for that end. The start of each match
+// is found by running the reversed pattern back from its end, taking the shortest
+// match with the same number of errors.
+func (b *Bitap) Find(t string, maxE int, op Options) ([]Match, error) {
+	if t == "" {
+		return nil, fmt.Errorf("text to search is empty")
+	} else if maxE < 0 {
+		return []Match{}, nil
+	}
+	text := []rune(t)
+	matches := []Match{}
+	b.scan(text, maxE, false, op, func(end int, dist int) bool {
+		if end == 0 {
+			// Only the pattern deleted entirely ends before the text
+			return true
+		}
+		matches = append(matches, Match{Start: b.start(text, end, dist, op), End: end, Dist: dist})
+		return true
+	})
+	return matches, nil
+}
+
+// start finds where the match of the pattern ending at end with dist errors begins
+func (b *Bitap) start(text []rune, end int, dist int, op Options) int {
+	// A match spans at most the pattern plus one text rune per error
+	from := max(0, end-len(b.elems)-dist)
+	backwards := make([]rune, end-from)
+	for i := range backwards {
+		backwards[i] = text[end-1-i]
+	}
+	start := end
+	b.reverse.scan(backwards, dist, true, op, func(j int, d int) bool {
+		start = end - j
+		return false
+	})
+	return start
+}
@@ -0,0 +1,39 @@
+package approx
+
+import (
+	"fmt"
+)
+
+// A Finder is a pattern set up to be searched for in many texts with the same
+// maxE and Options, like the reads of a sequencing run. It reuses its matrix
+// between searches, so it must not be used from several goroutines at once.
+type Finder struct {
+	pattern string
+	maxE    int
+	op      Options
+	c       LevenContext
+}
+
+// Compile checks the pattern and options once and returns a Finder for them
+func Compile(pattern string, maxE int, op Options) (*Finder, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern to search empty")
+	} else if op.Matches == nil {
+		return nil, fmt.Errorf("options have no Matches function")
+	}
+	pn, _, _ := op.prepare(pattern, "")
+	if err := op.checkWeights(len(pn)); err != nil {
+		return nil, err
+	}
+	return &Finder{pattern: pattern, maxE: maxE, op: op}, nil
+}
+
+// Find finds the pattern in text like ApproxFind
+func (f *Finder) Find(text string) ([]Match, error) {
+	return f.c.ApproxLeven(f.pattern, text, f.maxE, f.op)
+}
+
+// Pattern returns the pattern the Finder searches for
+func (f *Finder) Pattern() string {
+	return f.pattern
+}
@@ -0,0 +1,62 @@
+package approx
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Distance returns the edit distance between the whole of a and the whole of b,
+// using the costs in op. It is MaxInt if op.Weights rules out every alignment, or
+// doesn't have one entry per rune of a.
+func Distance(a string, b string, op Options) int {
+	c := LevenContext{}
+	return c.Distance(a, b, op)
+}
+
+// GlobalAlign aligns the whole of pattern against the whole of text, using the costs
+// and traceback policy in op. The Match always spans the text, with Dist being the
+// edit distance between the two.
+func GlobalAlign(pattern string, text string, op Options) (Alignment, error) {
+	c := LevenContext{}
+	return c.GlobalAlign(pattern, text, op)
+}
+
+// Distance is the same as the package level Distance, reusing the context's matrix
+func (c *LevenContext) Distance(a string, b string, op Options) int {
+	pattern, nt, op := op.prepare(a, b)
+	text := nt.runes
+	if err := op.checkWeights(len(pattern)); err != nil {
+		return MaxInt
+	}
+	op.MaxErrorRate = 0
+	matrix, _, _ := c.levenMatrix(pattern, text, MaxInt, 0, op)
+	if matrix[len(pattern)][len(text)] >= unreachable {
+		return MaxInt
+	}
+	return matrix[len(pattern)][len(text)]
+}
+
+// GlobalAlign uses the Needleman-Wunsch algorithm, the same matrix as ApproxLeven
+// but without the free text on either side of the pattern, to align all of p to all
+// of t. Either string may be empty.
+func (c *LevenContext) GlobalAlign(p string, t string, op Options) (Alignment, error) {
+	pattern, nt, op := op.prepare(p, t)
+	text := nt.runes
+	if err := op.checkWeights(len(pattern)); err != nil {
+		return Alignment{}, err
+	}
+	if len(pattern) == 0 && len(text) == 0 {
+		// The only cell is both the start and the end, so there is nothing to trace
+		return Alignment{Match: Match{End: utf8.RuneCountInString(t)}, Ops: []EditOp{}}, nil
+	}
+	// No end gaps are free, and the whole pattern is aligned whatever the rate
+	op.MaxErrorRate = 0
+	matrix, _, endCells := c.levenMatrix(pattern, text, MaxInt, 0, op)
+	alignments := traceAlignments(matrix, nil, pattern, text, endCells, 0, op)
+	if len(alignments) == 0 {
+		return Alignment{}, fmt.Errorf("can't traceback global alignment")
+	}
+	// The match spans the whole of the original text
+	alignments[0].End = utf8.RuneCountInString(t)
+	return alignments[0], nil
+}
@@ -0,0 +1,173 @@
+package approx
+
+import (
+	"unicode"
+)
+
+// clusterBase is where the runes standing in for multi-rune grapheme clusters
+// start, past the end of Unicode so they can't be mistaken for real runes
+const clusterBase = unicode.MaxRune + 1
+
+// prepare turns the pattern and the text into the runes that are compared,
+// applying op.Normalize and, with op.Graphemes, turning each grapheme cluster into
+// a single rune. The returned Options compare those runes.
+func (op Options) prepare(p string, t string) ([]rune, normText, Options) {
+	pattern := op.normalize(p)
+	text := op.normalize(t)
+	if !op.Graphemes {
+		return pattern.runes, text, op
+	}
+	in := &clusterTable{ids: make(map[string]rune)}
+	matches := op.Matches
+	op.Matches = func(a rune, b rune) bool {
+		if a >= clusterBase || b >= clusterBase {
+			return a == b
+		}
+		return matches(a, b)
+	}
+	return in.clusters(pattern).runes, in.clusters(text), op
+}
+
+// clusterTable hands out a rune for each distinct multi-rune grapheme cluster
+type clusterTable struct {
+	ids map[string]rune
+}
+
+// clusters groups the runes of n into grapheme clusters, keeping track of the
+// original runes each cluster came from
+func (in *clusterTable) clusters(n normText) normText {
+	c := normText{runes: []rune{}, starts: []int{}, ends: []int{}, size: n.size}
+	if n.starts == nil {
+		c.size = len(n.runes)
+	}
+	for a := 0; a < len(n.runes); {
+		b := nextBoundary(n.runes, a)
+		r := n.runes[a]
+		if b-a > 1 {
+			key := string(n.runes[a:b])
+			id, ok := in.ids[key]
+			if !ok {
+				id = clusterBase + rune(len(in.ids))
+				in.ids[key] = id
+			}
+			r = id
+		}
+		c.runes = append(c.runes, r)
+		if n.starts == nil {
+			c.starts = append(c.starts, a)
+			c.ends = append(c.ends, b)
+		} else {
+			c.starts = append(c.starts, n.starts[a])
+			c.ends = append(c.ends, n.ends[b-1])
+		}
+		a = b
+	}
+	return c
+}
+
+// graphemeClass is the part of the Unicode grapheme cluster break property that
+// the segmentation below uses
+type graphemeClass int
+
+const (
+	gcOther graphemeClass = iota
+	gcCR
+	gcLF
+	gcControl
+	gcExtend
+	gcZWJ
+	gcRegional
+	gcSpacingMark
+	gcL
+	gcV
+	gcT
+	gcLV
+	gcLVT
+	gcPictographic
+)
+
+// classify approximates the grapheme cluster break property of r from the
+// general categories in package unicode and the Hangul and emoji blocks
+func classify(r rune) graphemeClass {
+	switch {
+	case r == '\r':
+		return gcCR
+	case r == '\n':
+		return gcLF
+	case r == 0x200D:
+		return gcZWJ
+	case r == 0x200C, 0x1F3FB <= r && r <= 0x1F3FF, 0xE0020 <= r && r <= 0xE007F:
+		// ZWNJ, emoji skin tone modifiers, and emoji tags
+		return gcExtend
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return gcExtend
+	case unicode.In(r, unicode.Cc, unicode.Zl, unicode.Zp):
+		return gcControl
+	case unicode.Is(unicode.Mc, r):
+		return gcSpacingMark
+	case 0x1F1E6 <= r && r <= 0x1F1FF:
+		return gcRegional
+	case 0x1100 <= r && r <= 0x115F, 0xA960 <= r && r <= 0xA97C:
+		return gcL
+	case 0x1160 <= r && r <= 0x11A7, 0xD7B0 <= r && r <= 0xD7C6:
+		return gcV
+	case 0x11A8 <= r && r <= 0x11FF, 0xD7CB <= r && r <= 0xD7FB:
+		return gcT
+	case 0xAC00 <= r && r <= 0xD7A3:
+		if (r-0xAC00)%28 == 0 {
+			return gcLV
+		}
+		return gcLVT
+	case 0x1F000 <= r && r <= 0x1FAFF, 0x2600 <= r && r <= 0x27BF, 0x2300 <= r && r <= 0x23FF,
+		0x2B00 <= r && r <= 0x2BFF, r == 0xA9, r == 0xAE, r == 0x203C, r == 0x2049, r == 0x2122:
+		return gcPictographic
+	}
+	return gcOther
+}
+
+// nextBoundary returns the end of the grapheme cluster starting at runes[a],
+// following the extended grapheme cluster rules of UAX #29 without Prepend
+func nextBoundary(runes []rune, a int) int {
+	prev := classify(runes[a])
+	// Whether the cluster so far is a pictograph followed by Extends, and how
+	// many regional indicators it has
+	pictographic := prev == gcPictographic
+	regional := 0
+	if prev == gcRegional {
+		regional = 1
+	}
+	b := a + 1
+	for ; b < len(runes); b++ {
+		next := classify(runes[b])
+		join := false
+		switch {
+		case prev == gcCR && next == gcLF:
+			join = true
+		case prev == gcCR || prev == gcLF || prev == gcControl:
+		case next == gcCR || next == gcLF || next == gcControl:
+		case prev == gcL && (next == gcL || next == gcV || next == gcLV || next == gcLVT):
+			join = true
+		case (prev == gcLV || prev == gcV) && (next == gcV || next == gcT):
+			join = true
+		case (prev == gcLVT || prev == gcT) && next == gcT:
+			join = true
+		case next == gcExtend || next == gcZWJ || next == gcSpacingMark:
+			join = true
+		case prev == gcZWJ && next == gcPictographic && pictographic:
+			join = true
+		case prev == gcRegional && next == gcRegional && regional%2 == 1:
+			join = true
+		}
+		if !join {
+			break
+		}
+		if next == gcRegional {
+			regional++
+		}
+		if next != gcExtend && next != gcZWJ {
+			pictographic = next == gcPictographic
+		}
+		prev = next
+	}
+	return b
+}
@@ -0,0 +1,98 @@
+package approx
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// HammingFind finds every place in text where pattern occurs with at most maxMM
+// mismatches, and no insertions or deletions. This is much faster than ApproxFind
+// when indels are rare enough to ignore. Runes are compared with op.Matches, the
+// costs in op are not used, and Dist is the number of mismatches.
+func HammingFind(pattern string, text string, maxMM int, op Options) ([]Match, error) {
+	// Check for empty strings first
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern to search empty")
+	} else if text == "" {
+		return nil, fmt.Errorf("text to search is empty")
+	}
+	p := []rune(pattern)
+	t := []rune(text)
+	if maxMM < 0 || len(p) > len(t) {
+		return []Match{}, nil
+	}
+	fieldBits := bits.Len(uint(maxMM)) + 1
+	if len(p)*fieldBits > 64 {
+		return hammingLoop(p, t, maxMM, op), nil
+	}
+	return hammingShiftAdd(p, t, maxMM, fieldBits, op), nil
+}
+
+// hammingShiftAdd is the shift-add algorithm of Baeza-Yates and Gonnet. Each
+// pattern position gets a field of fieldBits bits in a single word, counting the
+// mismatches of the pattern prefix ending there against the text ending at the
+// current position. The top bit of each field catches counts too big for the
+// field, which are remembered in overflow and cleared so they can't carry into the
+// next field.
+func hammingShiftAdd(p []rune, t []rune, maxMM int, fieldBits int, op Options) []Match {
+	var high uint64
+	for i := range p {
+		high |= 1 << uint(i*fieldBits+fieldBits-1)
+	}
+	last := uint((len(p) - 1) * fieldBits)
+	lowMask := uint64(1)<<uint(fieldBits-1) - 1
+
+	// mismatch masks are built lazily for each distinct text rune
+	masks := make(map[rune]uint64)
+	mismatches := func(r rune) uint64 {
+		if mask, ok := masks[r]; ok {
+			return mask
+		}
+		var mask uint64
+		for i, pr := range p {
+			if !op.Matches(pr, r) {
+				mask |= 1 << uint(i*fieldBits)
+			}
+		}
+		masks[r] = mask
+		return mask
+	}
+
+	matches := []Match{}
+	var state, overflow uint64
+	for j, r := range t {
+		state = state<<uint(fieldBits) + mismatches(r)
+		overflow = overflow<<uint(fieldBits) | state&high
+		state &^= high
+		if j < len(p)-1 || (overflow>>last)&(lowMask+1) != 0 {
+			continue
+		}
+		dist := int((state >> last) & lowMask)
+		if dist > maxMM {
+			continue
+		}
+		matches = append(matches, Match{Start: j - len(p) + 1, End: j + 1, Dist: dist})
+	}
+	return matches
+}
+
+// hammingLoop compares the pattern at every offset, for patterns too long to fit
+// in a word
+func hammingLoop(p []rune, t []rune, maxMM int, op Options) []Match {
+	matches := []Match{}
+	for i := 0; i <= len(t)-len(p); i++ {
+		mm := 0
+		for j := range p {
+			if !op.Matches(p[j], t[i+j]) {
+				mm++
+				if mm > maxMM {
+					break
+				}
+			}
+		}
+		if mm <= maxMM {
+			matches = append(matches, Match{Start: i, End: i + len(p), Dist: mm})
+		}
+	}
+	return matches
+}
@@ -11,13 +11,26 @@ const MaxInt = int(^uint(0) >> 1)
 // LevenContext provides a reusable int Matrix
 type LevenContext struct {
 	matrix [][]int
+	lens   [][]int
 }
 
-func (c *LevenContext) getMatrix(height int) [][]int {
+// getMatrix returns a height by width matrix of zeros, reusing the rows of
+// earlier searches when they are long enough
+func (c *LevenContext) getMatrix(height int, width int) [][]int {
 	if cap(c.matrix) < height {
 		c.matrix = make([][]int, height)
 	}
-	return c.matrix[:height][:height]
+	matrix := c.matrix[:height]
+	for i := range matrix {
+		if cap(matrix[i]) < width {
+			matrix[i] = make([]int, width)
+		}
+		matrix[i] = matrix[i][:width]
+		for j := range matrix[i] {
+			matrix[i][j] = 0
+		}
+	}
+	return matrix
 }
 
 // approxLeven is a wrapper for calling the distance function with the context struct
@@ -27,7 +40,7 @@ func approxLeven(pattern []rune, text []rune, maxE int, op Options) ([]Match, er
 }
 
 // Use the leven alogorithm to find the best match of pattern in text with up to maxE edit dist
-// It is expected that the user has already normalized: https://blog.golang.org/normalization
+// Either normalize the strings first (https://blog.golang.org/normalization) or set op.Normalize
 // Leven adapted from https://github.com/texttheater/golang-levenshtein/blob/master/levenshtein/levenshtein.go
 // Note this is a little wierd right now because I'm hijacking what I had in order to add a context struct
 func (c *LevenContext) ApproxLeven(p string, t string, maxE int, op Options) ([]Match, error) {
@@ -38,109 +51,445 @@ func (c *LevenContext) ApproxLeven(p string, t string, maxE int, op Options) ([]
 	} else if t == "" {
 		return nil, fmt.Errorf("text to search is empty")
 	}
-	pattern := []rune(p)
-	text := []rune(t)
-	height := len(pattern) + 1
-	width := len(text) + 1
-	matrix := c.getMatrix(height)
+	pattern, nt, op := op.prepare(p, t)
+	text := nt.runes
+	if len(pattern) == 0 || len(text) == 0 {
+		return nil, fmt.Errorf("pattern or text is empty once normalized")
+	} else if err := op.checkWeights(len(pattern)); err != nil {
+		return nil, err
+	}
+	ends := op.endGaps()
+	matrix, lens, endCells := c.levenMatrix(pattern, text, maxE, ends, op)
+	if endCells == nil {
+		return nil, nil
+	}
+	matches, err := trace(matrix, lens, pattern, text, endCells, ends, op)
+	if err != nil {
+		return matches, fmt.Errorf("can't traceback matches: %v", err)
+	}
+	return nt.originals(matches), nil
+
+}
+
+// ApproxLevenAlignments works like ApproxLeven, but returns the edits of each
+// alignment as well. With op.MaxCoOptimal above 1, every equally good alignment
+// ending at a column is returned, up to that cap, instead of only the first one.
+func (c *LevenContext) ApproxLevenAlignments(p string, t string, maxE int, op Options) ([]Alignment, error) {
+
+	// Check for empty strings first
+	if p == "" {
+		return nil, fmt.Errorf("pattern to search empty")
+	} else if t == "" {
+		return nil, fmt.Errorf("text to search is empty")
+	}
+	pattern, nt, op := op.prepare(p, t)
+	text := nt.runes
+	if len(pattern) == 0 || len(text) == 0 {
+		return nil, fmt.Errorf("pattern or text is empty once normalized")
+	} else if err := op.checkWeights(len(pattern)); err != nil {
+		return nil, err
+	}
+	ends := op.endGaps()
+	matrix, lens, endCells := c.levenMatrix(pattern, text, maxE, ends, op)
+	if endCells == nil {
+		return nil, nil
+	}
+	alignments := traceAlignments(matrix, lens, pattern, text, endCells, ends, op)
+	for i := range alignments {
+		alignments[i].Match = nt.original(alignments[i].Match)
+	}
+	return alignments, nil
+}
+
+// cell is a position in the matrix, i is the row in the pattern and j is the
+// column in the text
+type cell struct {
+	i, j int
+}
+
+// isStart reports whether an alignment can begin at matrix[i][j] for free
+func (e EndGaps) isStart(i int, j int) bool {
+	return (i == 0 && (j == 0 || e&FreeTextPrefix != 0)) || (j == 0 && e&FreePatternPrefix != 0)
+}
+
+// isEnd reports whether an alignment can finish at matrix[i][j], for a pattern of
+// length m and a text of length n, without paying for the rest of either
+func (e EndGaps) isEnd(i int, j int, m int, n int) bool {
+	return (i == m && (j == n || e&FreeTextSuffix != 0)) || (j == n && e&FreePatternSuffix != 0)
+}
+
+// initMatrix sizes the matrix and fills in the first row and column
+func (c *LevenContext) initMatrix(height int, width int, ends EndGaps, op Options) [][]int {
+	matrix := c.getMatrix(height, width)
 
 	// Initialize trivial distances (from/to empty string). That is, fill
-	// the left column and the top row with row/column indices.
-	for i := 0; i < height; i++ {
-		matrix[i] = make([]int, width)
-		matrix[i][0] = i
+	// the left column with the cost of deleting the pattern so far, unless
+	// the start of the pattern can hang off the start of the text.
+	for i := 1; i < height; i++ {
+		if ends&FreePatternPrefix == 0 {
+			matrix[i][0] = addCost(matrix[i-1][0], op.delCost(i))
+		}
 	}
-	// Set the top row to 0's
+	// Set the top row to 0's, unless the text before the pattern must be
+	// paid for
 	for j := 1; j < width; j++ {
-		matrix[0][j] = 0
+		if ends&FreeTextPrefix == 0 {
+			matrix[0][j] = j * op.insCost(0)
+		}
 	}
+	return matrix
+}
+
+// levenMatrix fills the matrix for pattern against text and returns it along with
+// the aligned lengths, nil without op.MaxErrorRate, and the end cells whose
+// distance is within maxE and the rate. The cells are nil if no alignment can be
+// within them.
+func (c *LevenContext) levenMatrix(pattern []rune, text []rune, maxE int, ends EndGaps, op Options) ([][]int, [][]int, []cell) {
+	height := len(pattern) + 1
+	width := len(text) + 1
+	matrix := c.initMatrix(height, width, ends, op)
+	lens := c.getLens(height, width, ends, op)
+	// No alignment aligns more than the whole pattern
+	maxE = min(maxE, op.rateLimit(len(pattern)))
 
 	// Fill in the remaining cells: for each prefix pair, choose the
 	// (edit history, operation) pair with the lowest cost.
 	for i := 1; i < height; i++ {
-		currentMin := MaxInt
+		currentMin := matrix[i][0]
 		for j := 1; j < width; j++ {
-			delCost := matrix[i-1][j] + op.DelCost
-			matchSubCost := matrix[i-1][j-1]
-			if !op.Matches(pattern[i-1], text[j-1]) {
-				matchSubCost += op.SubCost
-			}
-			insCost := matrix[i][j-1] + op.InsCost
-			matrix[i][j] = min(delCost, min(matchSubCost,
-				insCost))
+			matrix[i][j] = levenCell(matrix, pattern, text, i, j, op)
+			if lens != nil {
+				lens[i][j] = alignedLen(matrix, lens, pattern, text, i, j, op)
+			}
 			if matrix[i][j] < currentMin {
 				currentMin = matrix[i][j]
 			}
 		}
 		// Check to see if the min for the row is greater than the
-		// max allowed
-		if currentMin > maxE {
-			return nil, nil
+		// max allowed. If the pattern can end early, rows above this one may
+		// still hold an end.
+		if currentMin > maxE && ends&FreePatternSuffix == 0 {
+			return matrix, lens, nil
 		}
 	}
 	//LogMatrix(pattern, text, matrix)
-	// Return a traceback for each alignment less than maxE
-	minCols := []int{}
-	for j := 0; j <= len(text); j++ {
-		if matrix[len(pattern)][j] <= maxE {
-			minCols = append(minCols, j)
+	// Return a traceback for each alignment less than maxE, ordered by
+	// column then row
+	endCells := []cell{}
+	for j := 0; j < width; j++ {
+		for i := 0; i < height; i++ {
+			if !ends.isEnd(i, j, len(pattern), len(text)) || ends.isStart(i, j) {
+				// An alignment that starts where it ends aligns nothing
+				continue
+			}
+			if matrix[i][j] <= maxE && matrix[i][j] < unreachable && withinRate(matrix, lens, i, j, op) {
+				endCells = append(endCells, cell{i, j})
+			}
 		}
 	}
+	return matrix, lens, endCells
+}
 
-	// If we somehow end up with no good matches
-	//	if len(minCols) == 0 {
-	//		return []Match{}
-	//	}
-	matches, err := trace(matrix, pattern, text, minCols, op)
-	if err != nil {
-		return matches, fmt.Errorf("can't traceback matches: %v", err)
+// levenCell computes matrix[i][j] from its upper, left, and upper left neighbours,
+// choosing the (edit history, operation) pair with the lowest cost
+func levenCell(matrix [][]int, pattern []rune, text []rune, i int, j int, op Options) int {
+	delCost := addCost(matrix[i-1][j], op.delCost(i))
+	matchSubCost := matrix[i-1][j-1]
+	if !op.Matches(pattern[i-1], text[j-1]) {
+		matchSubCost = addCost(matchSubCost, op.subCost(i, j))
 	}
-	return matches, nil
+	insCost := addCost(matrix[i][j-1], op.insCost(i))
+	best := min(delCost, min(matchSubCost, insCost))
+	if transposed(pattern, text, i, j, op) {
+		best = min(best, addCost(matrix[i-2][j-2], op.transCost(i)))
+	}
+	return best
+}
 
+// transposed reports whether the last two pattern runes before row i are the
+// last two text runes before column j swapped, when transpositions are allowed.
+// Only adjacent runes are swapped and neither is edited again, which makes this
+// the optimal string alignment variant of the Damerau-Levenshtein distance.
+func transposed(pattern []rune, text []rune, i int, j int, op Options) bool {
+	return op.TransCost > 0 && i > 1 && j > 1 &&
+		op.Matches(pattern[i-1], text[j-2]) && op.Matches(pattern[i-2], text[j-1])
 }
 
-// Traceback to find all the lowest edit distances
-func trace(matrix [][]int, p []rune, t []rune, minCols []int, op Options) ([]Match, error) {
+// Traceback to find all the lowest edit distances. When op.MaxCoOptimal allows
+// several alignments per end, each distinct start is reported once. Otherwise
+// each end is walked back along its preferred moves, without collecting edits.
+func trace(matrix [][]int, lens [][]int, p []rune, t []rune, endCells []cell, ends EndGaps, op Options) ([]Match, error) {
 	// For each min alignment found, do a traceback
 	// I need the start, and end releative to the text, and the distance
 	// I have the end and the dist, just need the start
 	matches := []Match{}
-	for _, min := range minCols {
-		// Set the 'corner' that we will start looking in
-		i, j := len(p), min
-
-		for i > 0 {
-			diag, vert, horz := MaxInt, MaxInt, MaxInt
-			var delt int
-			if i > 0 && j > 0 {
-				if op.Matches(p[i-1], t[j-1]) {
-					delt = 0
-				} else {
-					delt = op.SubCost
+	if op.MaxCoOptimal <= 1 {
+		for _, end := range endCells {
+			start, ok := traceStart(matrix, lens, p, t, end, ends, op)
+			if !ok {
+				return matches, fmt.Errorf("no move back from cell %d, %d", start.i, start.j)
+			}
+			matches = append(matches, Match{Start: start.j, End: end.j, Dist: matrix[end.i][end.j]})
+		}
+		return matches, nil
+	}
+	seen := make(map[Match]bool)
+	for _, alignment := range traceAlignments(matrix, lens, p, t, endCells, ends, op) {
+		if !seen[alignment.Match] {
+			seen[alignment.Match] = true
+			matches = append(matches, alignment.Match)
+		}
+	}
+	return matches, nil
+}
+
+// traceStart walks back from end along the preferred move at every cell, the
+// path traceAlignments takes first, and returns the cell the alignment starts
+// at. It returns false and the cell it got stuck at if there is no move back.
+func traceStart(matrix [][]int, lens [][]int, p []rune, t []rune, end cell, ends EndGaps, op Options) (cell, bool) {
+	i, j := end.i, end.j
+	for !ends.isStart(i, j) {
+		var step traceStep
+		moved := false
+		if lens == nil {
+			step, moved = preferredStep(matrix, p, t, i, j, op)
+		} else {
+			// Only the moves that keep to the checked length will do
+			steps, n := traceSteps(matrix, p, t, i, j, op)
+			for _, s := range steps[:n] {
+				if lens[s.i][s.j]+stepLen(s.op) == lens[i][j] {
+					step, moved = s, true
+					break
+				}
+			}
+		}
+		if !moved {
+			return cell{i, j}, false
+		}
+		i, j = step.i, step.j
+	}
+	return cell{i, j}, true
+}
+
+// preferredStep returns the first of the moves traceSteps would return, only
+// checking the moves it has to
+func preferredStep(matrix [][]int, p []rune, t []rune, i int, j int, op Options) (traceStep, bool) {
+	var order [4]EditOp
+	switch op.Traceback {
+	case RightAlignGaps:
+		order = [4]EditOp{OpDel, OpIns, OpMatch, OpTrans}
+	case PreferLongestMatch:
+		order = [4]EditOp{OpIns, OpMatch, OpTrans, OpDel}
+	default:
+		order = [4]EditOp{OpMatch, OpTrans, OpDel, OpIns}
+	}
+	for _, o := range order {
+		switch o {
+		case OpMatch:
+			if i == 0 || j == 0 {
+				continue
+			}
+			if op.Matches(p[i-1], t[j-1]) {
+				if matrix[i-1][j-1] == matrix[i][j] {
+					return traceStep{i - 1, j - 1, OpMatch}, true
+				}
+			} else if addCost(matrix[i-1][j-1], op.subCost(i, j)) == matrix[i][j] {
+				return traceStep{i - 1, j - 1, OpSub}, true
+			}
+		case OpTrans:
+			if transposed(p, t, i, j, op) && addCost(matrix[i-2][j-2], op.transCost(i)) == matrix[i][j] {
+				return traceStep{i - 2, j - 2, OpTrans}, true
+			}
+		case OpDel:
+			if i > 0 && addCost(matrix[i-1][j], op.delCost(i)) == matrix[i][j] {
+				return traceStep{i - 1, j, OpDel}, true
+			}
+		case OpIns:
+			if j > 0 && addCost(matrix[i][j-1], op.insCost(i)) == matrix[i][j] {
+				return traceStep{i, j - 1, OpIns}, true
+			}
+		}
+	}
+	return traceStep{}, false
+}
+
+// traceStep is a move back through the matrix to a cell that could have
+// produced the current one
+type traceStep struct {
+	i, j int
+	op   EditOp
+}
+
+// traceSteps returns the moves back from matrix[i][j] that reproduce its value,
+// in the order of preference given by op.Traceback
+func traceSteps(matrix [][]int, p []rune, t []rune, i int, j int, op Options) ([4]traceStep, int) {
+	var diag, trans, vert, horz *traceStep
+	if i > 0 && j > 0 {
+		if op.Matches(p[i-1], t[j-1]) {
+			if matrix[i-1][j-1] == matrix[i][j] {
+				diag = &traceStep{i - 1, j - 1, OpMatch}
+			}
+		} else if addCost(matrix[i-1][j-1], op.subCost(i, j)) == matrix[i][j] {
+			diag = &traceStep{i - 1, j - 1, OpSub}
+		}
+	}
+	if transposed(p, t, i, j, op) && addCost(matrix[i-2][j-2], op.transCost(i)) == matrix[i][j] {
+		trans = &traceStep{i - 2, j - 2, OpTrans}
+	}
+	if i > 0 && addCost(matrix[i-1][j], op.delCost(i)) == matrix[i][j] {
+		// vertical, a pattern rune missing from the text
+		vert = &traceStep{i - 1, j, OpDel}
+	}
+	if j > 0 && addCost(matrix[i][j-1], op.insCost(i)) == matrix[i][j] {
+		// horizontal, an extra text rune
+		horz = &traceStep{i, j - 1, OpIns}
+	}
+
+	var order [4]*traceStep
+	switch op.Traceback {
+	case RightAlignGaps:
+		// Taking gaps as early as possible on the way back leaves them rightmost
+		order = [4]*traceStep{vert, horz, diag, trans}
+	case PreferLongestMatch:
+		order = [4]*traceStep{horz, diag, trans, vert}
+	default:
+		order = [4]*traceStep{diag, trans, vert, horz}
+	}
+	var steps [4]traceStep
+	n := 0
+	for _, step := range order {
+		if step != nil {
+			steps[n] = *step
+			n++
+		}
+	}
+	return steps, n
+}
+
+// traceAlignments walks back from each end cell to a cell where the alignment can
+// start for free, enumerating up to op.MaxCoOptimal (at least one) co-optimal
+// alignments per end. The first alignment for each end always follows the
+// preferred move at every cell. With aligned lengths, only the moves that keep to
+// the length each cell was checked with are taken. Shifting gaps can turn many
+// paths into the same alignment, like the placements of a gap in a run, so only
+// up to limit times the length of p and t paths are walked per end.
+func traceAlignments(matrix [][]int, lens [][]int, p []rune, t []rune, endCells []cell, ends EndGaps, op Options) []Alignment {
+	limit := max(op.MaxCoOptimal, 1)
+	pathLimit := limit * (len(p) + len(t) + 1)
+	alignments := []Alignment{}
+	for _, end := range endCells {
+		found, paths := 0, 0
+		seen := make(map[string]bool)
+		ops := []EditOp{}
+		var walk func(i, j int)
+		walk = func(i, j int) {
+			if ends.isStart(i, j) {
+				paths++
+				// Ops were collected back to front
+				edits := make([]EditOp, len(ops))
+				for k, o := range ops {
+					edits[len(ops)-1-k] = o
+				}
+				switch op.Traceback {
+				case LeftAlignGaps:
+					shiftGaps(edits, p, t, i, j, op, true)
+				case RightAlignGaps:
+					shiftGaps(edits, p, t, i, j, op, false)
+				}
+				if seen[string(edits)] {
+					// Normalizing made this a duplicate of an earlier alignment
+					return
+				}
+				seen[string(edits)] = true
+				alignments = append(alignments, Alignment{
+					Match:        Match{Start: j, End: end.j, Dist: matrix[end.i][end.j]},
+					PatternStart: i,
+					PatternEnd:   end.i,
+					Ops:          edits,
+				})
+				found++
+				return
+			}
+			steps, n := traceSteps(matrix, p, t, i, j, op)
+			for _, step := range steps[:n] {
+				if found >= limit || paths >= pathLimit {
+					return
+				} else if lens != nil && lens[step.i][step.j]+stepLen(step.op) != lens[i][j] {
+					continue
 				}
-				diag = matrix[i-1][j-1] + delt
+				ops = append(ops, step.op)
+				walk(step.i, step.j)
+				ops = ops[:len(ops)-1]
 			}
-			if i > 0 {
-				vert = matrix[i-1][j] + op.DelCost
+		}
+		walk(end.i, end.j)
+	}
+	return alignments
+}
+
+// shiftGaps moves every run of insertions or deletions in ops as far left (or
+// right) as it will go while the match next to it still matches and the gaps cost
+// the same in their new place, which leaves the cost of the alignment unchanged.
+// The alignment starts at p[i0] and t[j0].
+func shiftGaps(ops []EditOp, p []rune, t []rune, i0 int, j0 int, op Options, left bool) {
+	// Each shift moves a run one place, then the ops are walked again
+	for moved := true; moved; {
+		moved = false
+		// pi and ti are the pattern and text positions at the start of ops[k]
+		pi, ti := i0, j0
+		for k := 0; k < len(ops); {
+			if ops[k] == OpTrans {
+				pi, ti = pi+2, ti+2
+				k++
+				continue
+			} else if ops[k] != OpIns && ops[k] != OpDel {
+				pi, ti = pi+1, ti+1
+				k++
+				continue
 			}
-			if j > 0 {
-				horz = matrix[i][j-1] + op.InsCost
+			// Find the end of this run of gaps
+			e := k
+			for e < len(ops) && ops[e] == ops[k] {
+				e++
 			}
-			if diag <= vert && diag <= horz {
-				// diagonal was best, it was a match or mismatch
-				i--
-				j--
-			} else if vert <= horz {
-				// vertical was best, it was an insertion
-				i--
+			run := e - k
+			if left && k > 0 && ops[k-1] == OpMatch {
+				// The match before the run moves to the end of it
+				// Insertions move from row pi to row pi-1, and the deletions
+				// from the runes of rows pi+1..pi+run to rows pi..pi+run-1
+				var ok bool
+				if ops[k] == OpIns {
+					ok = op.Matches(p[pi-1], t[ti+run-1]) && op.insCost(pi-1) == op.insCost(pi)
+				} else {
+					ok = op.Matches(p[pi+run-1], t[ti-1]) && op.delCost(pi) == op.delCost(pi+run)
+				}
+				if ok {
+					ops[k-1], ops[e-1] = ops[e-1], OpMatch
+					moved = true
+					break
+				}
+			} else if !left && e < len(ops) && ops[e] == OpMatch {
+				// The match after the run moves to the start of it
+				ok := op.Matches(p[pi], t[ti])
+				if ops[k] == OpIns {
+					ok = ok && op.insCost(pi) == op.insCost(pi+1)
+				} else {
+					ok = ok && op.delCost(pi+1) == op.delCost(pi+run+1)
+				}
+				if ok {
+					ops[e], ops[k] = ops[k], OpMatch
+					moved = true
+					break
+				}
+			}
+			if ops[k] == OpIns {
+				ti += run
 			} else {
-				// horizontal was best, it was a deletion
-				j--
+				pi += run
 			}
+			k = e
 		}
-		matches = append(matches, Match{Start: j, End: min, Dist: matrix[len(p)][min]})
 	}
-	return matches, nil
 }
 
 // WriteMatrix writes a visual representation of the given matrix for the given
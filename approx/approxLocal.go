@@ -0,0 +1,118 @@
+package approx
+
+import (
+	"fmt"
+)
+
+// A LocalAlignment is an Alignment found by LocalAlign along with its score. Dist
+// counts the mismatches and gaps in the alignment.
+type LocalAlignment struct {
+	Alignment
+	Score int
+}
+
+// LocalAlign finds the parts of pattern that align to a part of text with a score of
+// at least minScore. Unlike ApproxFind, the whole pattern doesn't need to align,
+// which makes it suited to finding partial adapters at the ends of reads.
+func LocalAlign(pattern string, text string, minScore int, op ScoreOptions) ([]LocalAlignment, error) {
+	c := LevenContext{}
+	return c.LocalAlign(pattern, text, minScore, op)
+}
+
+// LocalAlign uses the Smith-Waterman algorithm to find local alignments between the
+// pattern and the text. For each end column in the text, the best scoring alignment
+// ending there is returned if it scores at least minScore. Like ApproxLeven, a single
+// hit shows up at several end columns; ResolveOverlaps can be used on the Matches.
+func (c *LevenContext) LocalAlign(p string, t string, minScore int, op ScoreOptions) ([]LocalAlignment, error) {
+
+	// Check for empty strings first
+	if p == "" {
+		return nil, fmt.Errorf("pattern to search empty")
+	} else if t == "" {
+		return nil, fmt.Errorf("text to search is empty")
+	} else if minScore < 1 {
+		return nil, fmt.Errorf("minimum score must be at least 1, got %d", minScore)
+	}
+	pattern := []rune(p)
+	text := []rune(t)
+	height := len(pattern) + 1
+	width := len(text) + 1
+	// Local alignments can start anywhere, so the first row and column are 0
+	matrix := c.getMatrix(height, width)
+	for i := 1; i < height; i++ {
+		for j := 1; j < width; j++ {
+			matrix[i][j] = localCell(matrix, pattern, text, i, j, op)
+		}
+	}
+
+	alignments := []LocalAlignment{}
+	for j := 1; j < width; j++ {
+		// The best row to end on in this column
+		bestRow := 0
+		for i := 1; i < height; i++ {
+			if matrix[i][j] > matrix[bestRow][j] {
+				bestRow = i
+			}
+		}
+		if matrix[bestRow][j] < minScore {
+			continue
+		}
+		alignments = append(alignments, localTrace(matrix, pattern, text, bestRow, j, op))
+	}
+	return alignments, nil
+}
+
+// localCell computes matrix[i][j] for a local alignment, which never drops below 0
+func localCell(matrix [][]int, pattern []rune, text []rune, i int, j int, op ScoreOptions) int {
+	diag := matrix[i-1][j-1] - op.MismatchPenalty
+	if op.Matches(pattern[i-1], text[j-1]) {
+		diag = matrix[i-1][j-1] + op.MatchScore
+	}
+	vert := matrix[i-1][j] - op.GapPenalty
+	horz := matrix[i][j-1] - op.GapPenalty
+	return max(0, max(diag, max(vert, horz)))
+}
+
+// localTrace walks back from matrix[i][j] until the score drops to 0, preferring
+// diagonal moves, then vertical, then horizontal
+func localTrace(matrix [][]int, p []rune, t []rune, i int, j int, op ScoreOptions) LocalAlignment {
+	endRow, endCol := i, j
+	ops := []EditOp{}
+	dist := 0
+	for i > 0 && j > 0 && matrix[i][j] > 0 {
+		match := op.Matches(p[i-1], t[j-1])
+		if match && matrix[i-1][j-1]+op.MatchScore == matrix[i][j] {
+			ops = append(ops, OpMatch)
+			i--
+			j--
+			continue
+		}
+		dist++
+		if !match && matrix[i-1][j-1]-op.MismatchPenalty == matrix[i][j] {
+			ops = append(ops, OpSub)
+			i--
+			j--
+		} else if matrix[i-1][j]-op.GapPenalty == matrix[i][j] {
+			// vertical, a pattern rune missing from the text
+			ops = append(ops, OpDel)
+			i--
+		} else {
+			// horizontal, an extra text rune
+			ops = append(ops, OpIns)
+			j--
+		}
+	}
+	// Ops were collected back to front
+	for a, b := 0, len(ops)-1; a < b; a, b = a+1, b-1 {
+		ops[a], ops[b] = ops[b], ops[a]
+	}
+	return LocalAlignment{
+		Alignment: Alignment{
+			Match:        Match{Start: j, End: endCol, Dist: dist},
+			PatternStart: i,
+			PatternEnd:   endRow,
+			Ops:          ops,
+		},
+		Score: matrix[endRow][endCol],
+	}
+}
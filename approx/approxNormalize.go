@@ -0,0 +1,106 @@
+package approx
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// A Normalizer normalizes s one segment at a time, calling emit with each
+// normalized segment and the number of bytes of s it came from, in order. The
+// segments are what lets matches on normalized runes be traced back to s. The
+// normalize package has Unicode normalizations.
+type Normalizer func(s string, emit func(segment string, size int))
+
+// CaseFold wraps a MatchFunction so that it ignores case. Both runes are folded
+// with unicode.SimpleFold to the smallest rune they fold to, the upper case for
+// ASCII letters, before they are handed to matches.
+func CaseFold(matches MatchFunction) MatchFunction {
+	return func(a rune, b rune) bool {
+		return matches(foldRune(a), foldRune(b))
+	}
+}
+
+// foldRune returns the smallest rune in the case folding orbit of r
+func foldRune(r rune) rune {
+	folded := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < folded {
+			folded = f
+		}
+	}
+	return folded
+}
+
+// CaseInsensitiveOptions are the DefaultOptions with case folding
+var CaseInsensitiveOptions Options = Options{
+	InsCost: 1,
+	DelCost: 1,
+	SubCost: 1,
+	Matches: CaseFold(DefaultOptions.Matches),
+}
+
+// normText is a normalized string along with, for each of its runes, the runes of
+// the original string it came from
+type normText struct {
+	runes  []rune
+	starts []int
+	ends   []int
+	// size is the number of runes in the original string
+	size int
+}
+
+// normalize applies op.Normalize to s, keeping for every normalized rune the
+// original runes it came from
+func (op Options) normalize(s string) normText {
+	if op.Normalize == nil {
+		return normText{runes: []rune(s)}
+	}
+	n := normText{runes: []rune{}, starts: []int{}, ends: []int{}}
+	at, from := 0, 0
+	op.Normalize(s, func(segment string, size int) {
+		next := at + utf8.RuneCountInString(s[from:from+size])
+		for _, r := range segment {
+			n.runes = append(n.runes, r)
+			n.starts = append(n.starts, at)
+			n.ends = append(n.ends, next)
+		}
+		at, from = next, from+size
+	})
+	n.size = at
+	return n
+}
+
+// original maps a match on the normalized runes back to the original string
+func (n normText) original(m Match) Match {
+	if n.starts == nil {
+		return m
+	}
+	if m.Start < m.End {
+		return Match{Start: n.starts[m.Start], End: n.ends[m.End-1], Dist: m.Dist}
+	}
+	// Nothing of the text is in the match
+	at := n.size
+	if m.Start < len(n.runes) {
+		at = n.starts[m.Start]
+	}
+	return Match{Start: at, End: at, Dist: m.Dist}
+}
+
+// originals maps matches on the normalized runes back to the original string,
+// dropping any that map to the same match as an earlier one, as the ends of
+// runes normalized from one original rune do
+func (n normText) originals(matches []Match) []Match {
+	if n.starts == nil {
+		return matches
+	}
+	seen := map[Match]bool{}
+	kept := matches[:0]
+	for _, m := range matches {
+		m = n.original(m)
+		if !seen[m] {
+			seen[m] = true
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
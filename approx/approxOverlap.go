@@ -0,0 +1,142 @@
+package approx
+
+import (
+	"fmt"
+	"sort"
+)
+
+// OverlapPolicy chooses how ResolveOverlaps collapses matches that describe the
+// same locus in the text
+type OverlapPolicy int
+
+const (
+	// KeepAll returns every match unchanged
+	KeepAll OverlapPolicy = iota
+	// BestPerEnd keeps only the best match for each End
+	BestPerEnd
+	// BestPerStart keeps only the best match for each Start
+	BestPerStart
+	// NonOverlapping greedily keeps the best matches, dropping any match that
+	// overlaps one that has already been kept
+	NonOverlapping
+	// Cluster groups matches whose spans overlap (transitively) and keeps the
+	// best match of each group
+	Cluster
+)
+
+// ResolveOverlaps post-processes the output of ApproxFind so that a single true hit
+// is reported once instead of once per end column. The best of two matches is the
+// one with the lower Dist, then the leftmost, then the longest, the same ordering
+// used by BestMatch. The returned matches are sorted by Start, then End.
+func ResolveOverlaps(matches []Match, policy OverlapPolicy) ([]Match, error) {
+	var resolved []Match
+	switch policy {
+	case KeepAll:
+		resolved = append([]Match{}, matches...)
+	case BestPerEnd:
+		resolved = bestPerKey(matches, func(m Match) int { return m.End })
+	case BestPerStart:
+		resolved = bestPerKey(matches, func(m Match) int { return m.Start })
+	case NonOverlapping:
+		resolved = greedyNonOverlapping(matches)
+	case Cluster:
+		resolved = bestPerCluster(matches)
+	default:
+		return nil, fmt.Errorf("unknown overlap policy %d", policy)
+	}
+	sortMatches(resolved)
+	return resolved, nil
+}
+
+// betterMatch reports whether a should be preferred over b
+func betterMatch(a, b Match) bool {
+	if a.Dist != b.Dist {
+		return a.Dist < b.Dist
+	}
+	if a.Start != b.Start {
+		return a.Start < b.Start
+	}
+	return (a.End - a.Start) > (b.End - b.Start)
+}
+
+// overlaps reports whether the spans of two matches share any text position.
+// Empty spans overlap anything that contains their position.
+func overlaps(a, b Match) bool {
+	return a.Start < max(b.End, b.Start+1) && b.Start < max(a.End, a.Start+1)
+}
+
+// sortMatches orders matches by Start, then End, then Dist
+func sortMatches(matches []Match) {
+	sort.Slice(matches, func(a, b int) bool {
+		if matches[a].Start != matches[b].Start {
+			return matches[a].Start < matches[b].Start
+		}
+		if matches[a].End != matches[b].End {
+			return matches[a].End < matches[b].End
+		}
+		return matches[a].Dist < matches[b].Dist
+	})
+}
+
+// bestPerKey keeps the best match for each value of key
+func bestPerKey(matches []Match, key func(Match) int) []Match {
+	best := make(map[int]Match)
+	for _, m := range matches {
+		if cur, ok := best[key(m)]; !ok || betterMatch(m, cur) {
+			best[key(m)] = m
+		}
+	}
+	resolved := make([]Match, 0, len(best))
+	for _, m := range best {
+		resolved = append(resolved, m)
+	}
+	return resolved
+}
+
+// greedyNonOverlapping takes matches best first, skipping any that overlap a kept match
+func greedyNonOverlapping(matches []Match) []Match {
+	ranked := append([]Match{}, matches...)
+	sort.SliceStable(ranked, func(a, b int) bool {
+		return betterMatch(ranked[a], ranked[b])
+	})
+	resolved := []Match{}
+	for _, m := range ranked {
+		keep := true
+		for _, k := range resolved {
+			if overlaps(m, k) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			resolved = append(resolved, m)
+		}
+	}
+	return resolved
+}
+
+// bestPerCluster sweeps the matches left to right, grouping any that overlap the
+// running span of the current group, and keeps the best of each group
+func bestPerCluster(matches []Match) []Match {
+	resolved := []Match{}
+	if len(matches) == 0 {
+		return resolved
+	}
+	sorted := append([]Match{}, matches...)
+	sortMatches(sorted)
+
+	best := sorted[0]
+	span := sorted[0]
+	for _, m := range sorted[1:] {
+		if overlaps(m, span) {
+			span.End = max(span.End, m.End)
+			if betterMatch(m, best) {
+				best = m
+			}
+			continue
+		}
+		resolved = append(resolved, best)
+		best, span = m, m
+	}
+	return append(resolved, best)
+}
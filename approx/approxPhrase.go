@@ -0,0 +1,107 @@
+package approx
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// A Token is a piece of a string, with Start and End being byte offsets into it
+type Token struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// A Tokenizer splits a string into Tokens
+type Tokenizer func(s string) []Token
+
+// WordTokenizer splits s into runs of letters, digits, and apostrophes, so
+// punctuation and spaces are dropped
+func WordTokenizer(s string) []Token {
+	tokens := []Token{}
+	start := -1
+	for i, r := range s {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' || unicode.Is(unicode.Mn, r)
+		if inWord && start < 0 {
+			start = i
+		} else if !inWord && start >= 0 {
+			tokens = append(tokens, Token{Text: s[start:i], Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, Token{Text: s[start:], Start: start, End: len(s)})
+	}
+	return tokens
+}
+
+// PhraseOptions are the options for FindPhrase. The embedded Options give the
+// costs of a missing, extra, or different word. Two words are the same if they
+// are within WordMaxE of each other using WordOptions, DefaultOptions if its
+// Matches is nil. Tokenizer splits the phrase and the text into words,
+// WordTokenizer if it is nil.
+type PhraseOptions struct {
+	Options
+	WordMaxE    int
+	WordOptions Options
+	Tokenizer   Tokenizer
+}
+
+// FindPhrase finds phrase in text word by word: each word may be misspelled by up
+// to op.WordMaxE edits, and the phrase as a whole may have up to maxE missing,
+// extra, or different words. Start and End are byte offsets into text, ready for
+// highlighting, and Dist counts the word edits.
+func FindPhrase(phrase string, text string, maxE int, op PhraseOptions) ([]Match, error) {
+	tokenize := op.Tokenizer
+	if tokenize == nil {
+		tokenize = WordTokenizer
+	}
+	phraseTokens := tokenize(phrase)
+	textTokens := tokenize(text)
+	if len(phraseTokens) == 0 {
+		return nil, fmt.Errorf("phrase has no words")
+	} else if len(textTokens) == 0 {
+		return nil, fmt.Errorf("text has no words")
+	}
+
+	wordOp := op.WordOptions
+	if wordOp.Matches == nil {
+		wordOp = DefaultOptions
+	}
+	// Each pair of words is only compared once
+	same := make(map[[2]string]bool)
+	equal := func(a string, b string) bool {
+		key := [2]string{a, b}
+		if s, ok := same[key]; ok {
+			return s
+		}
+		s := a == b || Distance(a, b, wordOp) <= op.WordMaxE
+		same[key] = s
+		return s
+	}
+
+	words := func(tokens []Token) []string {
+		ws := make([]string, len(tokens))
+		for i, tok := range tokens {
+			ws[i] = tok.Text
+		}
+		return ws
+	}
+	matches, err := FindSeq(words(phraseTokens), words(textTokens), maxE, SeqOptions[string]{Options: op.Options, Equal: equal})
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range matches {
+		if m.Start < m.End {
+			matches[i] = Match{Start: textTokens[m.Start].Start, End: textTokens[m.End-1].End, Dist: m.Dist}
+			continue
+		}
+		// No words of the text are in the match
+		at := len(text)
+		if m.Start < len(textTokens) {
+			at = textTokens[m.Start].Start
+		}
+		matches[i] = Match{Start: at, End: at, Dist: m.Dist}
+	}
+	return matches, nil
+}
@@ -0,0 +1,106 @@
+package approx
+
+import (
+	"fmt"
+	"math"
+)
+
+// qualScale is the fixed point scale of the costs used by ApproxLevenQual, so a
+// cost of 1 becomes 100 and a substitution at a base that is 90% likely to be
+// right costs 90
+const qualScale = 100
+
+// A QualMatch is a match found by ApproxFindQual. Dist is the number of edits in
+// the match, while Weighted is its cost with the substitutions scaled by the
+// quality of the text.
+type QualMatch struct {
+	Match
+	Weighted float64
+}
+
+// ApproxFindQual finds pattern in text like ApproxFind, but with each substitution
+// costing op.SubCost times the chance that the text base is right, given its Phred
+// quality in quals. A mismatch at Q10 costs 0.9, at Q3 0.5, and at Q30 0.999, so
+// mismatches at bases that are likely sequencing errors count for less. quals has
+// one score per text rune, not the ASCII of a FASTQ file, see DecodePhred. maxE
+// bounds the Weighted cost.
+func ApproxFindQual(pattern string, text string, quals []byte, maxE float64, op Options) ([]QualMatch, error) {
+	c := LevenContext{}
+	return c.ApproxLevenQual(pattern, text, quals, maxE, op)
+}
+
+// DecodePhred turns the quality line of a FASTQ record, in the usual Phred+33
+// encoding, into the scores used by ApproxFindQual
+func DecodePhred(qual string) ([]byte, error) {
+	quals := make([]byte, len(qual))
+	for i := 0; i < len(qual); i++ {
+		if qual[i] < 33 || qual[i] > 126 {
+			return nil, fmt.Errorf("bad quality character %q at %d", qual[i], i)
+		}
+		quals[i] = qual[i] - 33
+	}
+	return quals, nil
+}
+
+// ApproxLevenQual is the same as ApproxFindQual, reusing the context's matrix. It
+// scales every cost by qualScale and runs ApproxLevenAlignments, so that
+// substitution costs can be fractions of the other costs.
+func (c *LevenContext) ApproxLevenQual(p string, t string, quals []byte, maxE float64, op Options) ([]QualMatch, error) {
+	if n := len([]rune(t)); len(quals) != n {
+		return nil, fmt.Errorf("text has %d runes but %d qualities", n, len(quals))
+	}
+	if maxE < 0 {
+		return []QualMatch{}, nil
+	}
+	scaled := op
+	scaled.InsCost *= qualScale
+	scaled.DelCost *= qualScale
+	scaled.SubCost *= qualScale
+	scaled.TransCost *= qualScale
+	scaled.costScale = qualScale
+	if op.Weights != nil {
+		scaled.Weights = make([]PositionWeight, len(op.Weights))
+		for i, w := range op.Weights {
+			scaled.Weights[i] = PositionWeight{Sub: w.Sub * qualScale, Del: w.Del * qualScale, Ins: w.Ins * qualScale, MustMatch: w.MustMatch}
+		}
+	}
+	// With normalization or grapheme clusters, a text rune takes the lowest
+	// quality of the runes it came from
+	_, nt, _ := op.prepare(p, t)
+	scaled.textSub = make([]int, len(nt.runes))
+	for j := range nt.runes {
+		q := quals[j]
+		if nt.starts != nil {
+			q = quals[nt.starts[j]]
+			for _, o := range quals[nt.starts[j]:nt.ends[j]] {
+				if o < q {
+					q = o
+				}
+			}
+		}
+		// The chance that the base is right, in parts of qualScale
+		scaled.textSub[j] = int(math.Round(qualScale * (1 - math.Pow(10, -float64(q)/10))))
+	}
+
+	alignments, err := c.ApproxLevenAlignments(p, t, int(math.Floor(maxE*qualScale+1e-9)), scaled)
+	if err != nil {
+		return nil, err
+	}
+	matches := []QualMatch{}
+	seen := make(map[Match]bool)
+	for _, a := range alignments {
+		edits := 0
+		for _, o := range a.Ops {
+			if o != OpMatch {
+				edits++
+			}
+		}
+		m := Match{Start: a.Start, End: a.End, Dist: edits}
+		if seen[m] {
+			continue
+		}
+		seen[m] = true
+		matches = append(matches, QualMatch{Match: m, Weighted: float64(a.Dist) / qualScale})
+	}
+	return matches, nil
+}
@@ -0,0 +1,71 @@
+package approx
+
+import (
+	"math"
+)
+
+// rateLimit returns the highest cost op.MaxErrorRate allows an alignment of n
+// pattern runes, or unreachable if there is no rate
+func (op Options) rateLimit(n int) int {
+	if op.MaxErrorRate <= 0 {
+		return unreachable
+	}
+	// The small slack keeps rates like 0.29 * 100 from rounding down a whole error
+	limit := int(math.Floor(op.MaxErrorRate*float64(n) + 1e-9))
+	return limit * max(op.costScale, 1)
+}
+
+// getLens returns a matrix for the aligned lengths when op has a MaxErrorRate, or
+// nil when it doesn't need one
+func (c *LevenContext) getLens(height int, width int, ends EndGaps, op Options) [][]int {
+	if op.MaxErrorRate <= 0 {
+		return nil
+	}
+	if cap(c.lens) < height {
+		c.lens = make([][]int, height)
+	}
+	lens := c.lens[:height]
+	for i := range lens {
+		if cap(lens[i]) < width {
+			lens[i] = make([]int, width)
+		}
+		lens[i] = lens[i][:width]
+		for j := range lens[i] {
+			lens[i][j] = 0
+		}
+		// Pattern runes deleted before the text count, unless they hang off it
+		if ends&FreePatternPrefix == 0 {
+			lens[i][0] = i
+		}
+	}
+	return lens
+}
+
+// stepLen is the number of pattern runes an edit aligns
+func stepLen(o EditOp) int {
+	switch o {
+	case OpIns:
+		return 0
+	case OpTrans:
+		return 2
+	}
+	return 1
+}
+
+// alignedLen returns how many pattern runes the cheapest alignment ending at
+// matrix[i][j] aligns, the most of them when several are as cheap, since the
+// longer alignment is allowed more errors. It takes the same moves as traceSteps.
+func alignedLen(matrix [][]int, lens [][]int, pattern []rune, text []rune, i int, j int, op Options) int {
+	aligned := 0
+	steps, n := traceSteps(matrix, pattern, text, i, j, op)
+	for _, step := range steps[:n] {
+		aligned = max(aligned, lens[step.i][step.j]+stepLen(step.op))
+	}
+	return aligned
+}
+
+// withinRate reports whether the alignment ending at matrix[i][j] is within
+// op.MaxErrorRate, which it always is without a rate
+func withinRate(matrix [][]int, lens [][]int, i int, j int, op Options) bool {
+	return lens == nil || matrix[i][j] <= op.rateLimit(lens[i][j])
+}
@@ -0,0 +1,89 @@
+package approx
+
+import (
+	"fmt"
+)
+
+// SeqOptions are the Options for FindSeq. Equal decides whether two tokens match,
+// == is used if it is nil. Options.Matches, Normalize, and Graphemes are not used.
+type SeqOptions[T comparable] struct {
+	Options
+	Equal func(a T, b T) bool
+}
+
+// FindSeq finds pattern in text like ApproxFind, for sequences of any comparable
+// tokens instead of runes, like the words of a sentence or the codons of a gene.
+// Start and End index into text.
+func FindSeq[T comparable](pattern []T, text []T, maxE int, op SeqOptions[T]) ([]Match, error) {
+	p, t, ro, err := seqRunes(pattern, text, op)
+	if err != nil {
+		return nil, err
+	}
+	c := LevenContext{}
+	ends := ro.endGaps()
+	matrix, lens, endCells := c.levenMatrix(p, t, maxE, ends, ro)
+	if endCells == nil {
+		return nil, nil
+	}
+	matches, err := trace(matrix, lens, p, t, endCells, ends, ro)
+	if err != nil {
+		return matches, fmt.Errorf("can't traceback matches: %v", err)
+	}
+	return matches, nil
+}
+
+// FindSeqAlignments is FindSeq returning the edits of each alignment, like
+// ApproxFindAlignments
+func FindSeqAlignments[T comparable](pattern []T, text []T, maxE int, op SeqOptions[T]) ([]Alignment, error) {
+	p, t, ro, err := seqRunes(pattern, text, op)
+	if err != nil {
+		return nil, err
+	}
+	c := LevenContext{}
+	ends := ro.endGaps()
+	matrix, lens, endCells := c.levenMatrix(p, t, maxE, ends, ro)
+	if endCells == nil {
+		return nil, nil
+	}
+	return traceAlignments(matrix, lens, p, t, endCells, ends, ro), nil
+}
+
+// seqRunes stands a rune in for each distinct token, so the sequences can go
+// through the same matrix and traceback as strings, and returns Options whose
+// Matches compares the tokens behind the runes
+func seqRunes[T comparable](pattern []T, text []T, op SeqOptions[T]) ([]rune, []rune, Options, error) {
+	// Check for empty sequences first
+	if len(pattern) == 0 {
+		return nil, nil, op.Options, fmt.Errorf("pattern to search empty")
+	} else if len(text) == 0 {
+		return nil, nil, op.Options, fmt.Errorf("text to search is empty")
+	} else if err := op.checkWeights(len(pattern)); err != nil {
+		return nil, nil, op.Options, err
+	}
+	ids := make(map[T]rune)
+	tokens := []T{}
+	runes := func(seq []T) []rune {
+		rs := make([]rune, len(seq))
+		for i, tok := range seq {
+			id, ok := ids[tok]
+			if !ok {
+				id = rune(len(tokens))
+				ids[tok] = id
+				tokens = append(tokens, tok)
+			}
+			rs[i] = id
+		}
+		return rs
+	}
+	p := runes(pattern)
+	t := runes(text)
+
+	ro := op.Options
+	ro.Normalize = nil
+	ro.Graphemes = false
+	equal := op.Equal
+	ro.Matches = func(a rune, b rune) bool {
+		return a == b || (equal != nil && equal(tokens[a], tokens[b]))
+	}
+	return p, t, ro, nil
+}
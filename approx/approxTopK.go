@@ -0,0 +1,142 @@
+package approx
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ApproxFindBest returns only the single best match of pattern in text within maxE.
+// It is ApproxFindTopK with k = 1, and returns an error if nothing is found.
+func ApproxFindBest(pattern string, text string, maxE int, op Options) (Match, error) {
+	matches, err := ApproxFindTopK(pattern, text, 1, maxE, op)
+	if err != nil {
+		return Match{}, err
+	}
+	if len(matches) == 0 {
+		return Match{}, fmt.Errorf("No matches found")
+	}
+	return matches[0], nil
+}
+
+// ApproxFindTopK returns up to k of the best matches of pattern in text within maxE,
+// best first (lowest Dist, then leftmost, then longest). Unlike ApproxFind, only the
+// ends that can still be among the k best are traced back.
+func ApproxFindTopK(pattern string, text string, k int, maxE int, op Options) ([]Match, error) {
+	c := LevenContext{}
+	return c.ApproxLevenTopK(pattern, text, k, maxE, op)
+}
+
+// ApproxLevenTopK fills the Levenshtein matrix one text column at a time, keeping
+// only the k best distinct matches seen so far, ranked like BestMatch. Once k
+// matches are held, the effective maxE is tightened to the Dist of the worst of
+// them, and rows that can no longer reach it are skipped (Ukkonen's cut-off).
+func (c *LevenContext) ApproxLevenTopK(p string, t string, k int, maxE int, op Options) ([]Match, error) {
+
+	// Check for empty strings first
+	if p == "" {
+		return nil, fmt.Errorf("pattern to search empty")
+	} else if t == "" {
+		return nil, fmt.Errorf("text to search is empty")
+	} else if k < 1 {
+		return nil, fmt.Errorf("k must be at least 1, got %d", k)
+	}
+	pattern, nt, op := op.prepare(p, t)
+	text := nt.runes
+	if len(pattern) == 0 || len(text) == 0 {
+		return nil, fmt.Errorf("pattern or text is empty once normalized")
+	} else if err := op.checkWeights(len(pattern)); err != nil {
+		return nil, err
+	}
+	height := len(pattern) + 1
+	width := len(text) + 1
+	ends := op.endGaps()
+	matrix := c.initMatrix(height, width, ends, op)
+	lens := c.getLens(height, width, ends, op)
+
+	bound := min(maxE, op.rateLimit(len(pattern)))
+	best := []Match{}
+	var traceErr error
+	// offer matrix[i][j] as a candidate, traced back to its match, and tighten the
+	// bound once k distinct matches are held. Several end cells in the last
+	// column, or the ends of runes normalized from one original rune, can give
+	// the same match.
+	offer := func(i, j int) {
+		if !ends.isEnd(i, j, len(pattern), len(text)) || ends.isStart(i, j) || matrix[i][j] > bound || matrix[i][j] >= unreachable || !withinRate(matrix, lens, i, j, op) {
+			return
+		}
+		start, ok := traceStart(matrix, lens, pattern, text, cell{i, j}, ends, op)
+		if !ok {
+			traceErr = fmt.Errorf("no move back from cell %d, %d", start.i, start.j)
+			return
+		}
+		m := nt.original(Match{Start: start.j, End: j, Dist: matrix[i][j]})
+		for _, b := range best {
+			if b == m {
+				return
+			}
+		}
+		best = append(best, m)
+		sort.SliceStable(best, func(a, b int) bool { return betterMatch(best[a], best[b]) })
+		if len(best) > k {
+			best = best[:k]
+		}
+		if len(best) == k {
+			// A match as costly as the worst held can still start further left
+			// or be longer
+			bound = best[k-1].Dist
+		}
+	}
+	// offer the cells of column j that can end an alignment
+	offerColumn := func(j int) {
+		if j == len(text) {
+			for i := 0; i < height; i++ {
+				offer(i, j)
+			}
+		} else {
+			offer(len(pattern), j)
+		}
+	}
+	offerColumn(0)
+
+	// The last row in the previous column that was within the bound, and in the
+	// column before that for transpositions
+	lastActive := len(pattern)
+	for i := 0; i < height; i++ {
+		if matrix[i][0] > bound {
+			lastActive = i - 1
+			break
+		}
+	}
+
+	prevActive := lastActive
+	for j := 1; j < width && bound >= 0; j++ {
+		active := -1
+		if matrix[0][j] <= bound {
+			active = 0
+		}
+		for i := 1; i < height; i++ {
+			if i > lastActive+1 && (op.TransCost == 0 || i > prevActive+2) && matrix[i-1][j] > bound {
+				// Nothing below here can come back under the bound, the
+				// sentinel is a lower bound on the true value
+				for ; i < height; i++ {
+					matrix[i][j] = bound + 1
+				}
+				break
+			}
+			matrix[i][j] = levenCell(matrix, pattern, text, i, j, op)
+			if lens != nil {
+				lens[i][j] = alignedLen(matrix, lens, pattern, text, i, j, op)
+			}
+			if matrix[i][j] <= bound {
+				active = i
+			}
+		}
+		prevActive, lastActive = lastActive, active
+		offerColumn(j)
+	}
+
+	if traceErr != nil {
+		return nil, fmt.Errorf("can't traceback matches: %v", traceErr)
+	}
+	return best, nil
+}
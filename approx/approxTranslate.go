@@ -0,0 +1,144 @@
+package approx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GeneticCode maps each codon, in upper case DNA, to its amino acid, with '*' for
+// a stop codon
+type GeneticCode map[string]byte
+
+// bases is the order of the codons in an NCBI translation table
+const bases = "TCAG"
+
+// NewGeneticCode builds a GeneticCode from the 64 amino acids of an NCBI
+// translation table, the "AAs" line, with the codons in TCAG order
+// (TTT, TTC, TTA, TTG, TCT, ...).
+func NewGeneticCode(aminoAcids string) (GeneticCode, error) {
+	if len(aminoAcids) != 64 {
+		return nil, fmt.Errorf("genetic code needs 64 amino acids, got %d", len(aminoAcids))
+	}
+	code := make(GeneticCode, 64)
+	for i := 0; i < 64; i++ {
+		codon := string([]byte{bases[i/16], bases[i/4%4], bases[i%4]})
+		code[codon] = aminoAcids[i]
+	}
+	return code, nil
+}
+
+// StandardCode is the standard genetic code, NCBI table 1
+var StandardCode GeneticCode = mustGeneticCode("FFLLSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG")
+
+func mustGeneticCode(aminoAcids string) GeneticCode {
+	code, err := NewGeneticCode(aminoAcids)
+	if err != nil {
+		panic(err)
+	}
+	return code
+}
+
+// Translate translates dna codon by codon from its first base, dropping any
+// bases left over at the end. Lower case and U are accepted, and codons that
+// aren't in the code, like those with an N, become 'X'.
+func (code GeneticCode) Translate(dna string) string {
+	dna = strings.Replace(strings.ToUpper(dna), "U", "T", -1)
+	protein := make([]byte, len(dna)/3)
+	for i := range protein {
+		aa, ok := code[dna[3*i:3*i+3]]
+		if !ok {
+			aa = 'X'
+		}
+		protein[i] = aa
+	}
+	return string(protein)
+}
+
+// complements pairs each base, IUPAC codes included, with its complement
+var complements = map[byte]byte{
+	'A': 'T', 'C': 'G', 'G': 'C', 'T': 'A', 'U': 'A', 'N': 'N',
+	'R': 'Y', 'Y': 'R', 'S': 'S', 'W': 'W', 'K': 'M', 'M': 'K',
+	'B': 'V', 'V': 'B', 'D': 'H', 'H': 'D',
+	'a': 't', 'c': 'g', 'g': 'c', 't': 'a', 'u': 'a', 'n': 'n',
+	'r': 'y', 'y': 'r', 's': 's', 'w': 'w', 'k': 'm', 'm': 'k',
+	'b': 'v', 'v': 'b', 'd': 'h', 'h': 'd',
+}
+
+// ReverseComplement returns the other strand of dna, read 5' to 3'. Anything that
+// isn't a base, like a gap, is kept as it is.
+func ReverseComplement(dna string) string {
+	rc := make([]byte, len(dna))
+	for i := 0; i < len(dna); i++ {
+		b := dna[len(dna)-1-i]
+		if c, ok := complements[b]; ok {
+			b = c
+		}
+		rc[i] = b
+	}
+	return string(rc)
+}
+
+// Strand is the strand of the DNA a match was found on
+type Strand byte
+
+const (
+	// Forward is the strand as given
+	Forward Strand = '+'
+	// Reverse is the reverse complement
+	Reverse Strand = '-'
+)
+
+// A TranslatedMatch is a match of a protein in DNA. Start and End are positions in
+// the DNA as given, whatever the strand, and always span whole codons. Frame is 0,
+// 1, or 2, the offset of the first codon from the start of the strand it was read
+// on, and Dist is the edit distance between the amino acids.
+type TranslatedMatch struct {
+	Match
+	Frame  int
+	Strand Strand
+}
+
+// TranslatedFind translates dna in all six frames with code, StandardCode if it is
+// nil, and finds protein in each translation with ApproxFind, so op gives the costs
+// of amino acid edits. Matches come forward strand first, then by frame.
+func TranslatedFind(protein string, dna string, maxE int, code GeneticCode, op Options) ([]TranslatedMatch, error) {
+	// Check for empty strings first
+	if protein == "" {
+		return nil, fmt.Errorf("pattern to search empty")
+	} else if dna == "" {
+		return nil, fmt.Errorf("text to search is empty")
+	}
+	if code == nil {
+		code = StandardCode
+	}
+	n := len(dna)
+	found := []TranslatedMatch{}
+	for _, strand := range []Strand{Forward, Reverse} {
+		seq := dna
+		if strand == Reverse {
+			seq = ReverseComplement(dna)
+		}
+		for frame := 0; frame < 3 && frame < n; frame++ {
+			aas := code.Translate(seq[frame:])
+			if aas == "" {
+				continue
+			}
+			matches, err := ApproxFind(protein, aas, maxE, op)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range matches {
+				start, end := frame+3*m.Start, frame+3*m.End
+				if strand == Reverse {
+					start, end = n-end, n-start
+				}
+				found = append(found, TranslatedMatch{
+					Match:  Match{Start: start, End: end, Dist: m.Dist},
+					Frame:  frame,
+					Strand: strand,
+				})
+			}
+		}
+	}
+	return found, nil
+}
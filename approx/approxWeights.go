@@ -0,0 +1,87 @@
+package approx
+
+import (
+	"fmt"
+)
+
+// unreachable is the cost of an edit that Options.Weights forbids. Sums are capped
+// at it by addCost, so a cell that can only be reached by a forbidden edit stays
+// unreachable.
+const unreachable = MaxInt / 4
+
+// UniformWeights returns a weight for every rune of pattern, each with the costs
+// in op, to be adjusted and set as op.Weights. For example, to make the last three
+// runes of a primer match exactly:
+//
+//	w := approx.UniformWeights(primer, op)
+//	for i := len(w) - 3; i < len(w); i++ {
+//		w[i].MustMatch = true
+//	}
+//	op.Weights = w
+func UniformWeights(pattern string, op Options) []PositionWeight {
+	weights := make([]PositionWeight, len([]rune(pattern)))
+	for i := range weights {
+		weights[i] = PositionWeight{Sub: op.SubCost, Del: op.DelCost, Ins: op.InsCost}
+	}
+	return weights
+}
+
+// checkWeights makes sure there is a weight for each of the m pattern runes
+func (op Options) checkWeights(m int) error {
+	if op.Weights != nil && len(op.Weights) != m {
+		return fmt.Errorf("pattern has %d runes but %d weights", m, len(op.Weights))
+	}
+	return nil
+}
+
+// subCost is the cost of substituting the pattern rune of row i for the text rune
+// of column j
+func (op Options) subCost(i int, j int) int {
+	cost := op.SubCost
+	if op.Weights != nil {
+		w := op.Weights[i-1]
+		if w.MustMatch {
+			return unreachable
+		}
+		cost = w.Sub
+	}
+	if op.textSub != nil {
+		cost = (cost*op.textSub[j-1] + qualScale/2) / qualScale
+	}
+	return cost
+}
+
+// delCost is the cost of deleting the pattern rune of row i
+func (op Options) delCost(i int) int {
+	if op.Weights == nil {
+		return op.DelCost
+	}
+	if w := op.Weights[i-1]; !w.MustMatch {
+		return w.Del
+	}
+	return unreachable
+}
+
+// insCost is the cost of inserting a text rune in row i, after i pattern runes
+func (op Options) insCost(i int) int {
+	if op.Weights == nil || i == 0 {
+		return op.InsCost
+	}
+	return op.Weights[i-1].Ins
+}
+
+// transCost is the cost of swapping the pattern runes of rows i-1 and i
+func (op Options) transCost(i int) int {
+	if op.Weights != nil && (op.Weights[i-2].MustMatch || op.Weights[i-1].MustMatch) {
+		return unreachable
+	}
+	return op.TransCost
+}
+
+// addCost adds an edit to a cost, capping the sum at unreachable
+func addCost(cost int, edit int) int {
+	if cost >= unreachable || edit >= unreachable || cost+edit >= unreachable {
+		return unreachable
+	}
+	return cost + edit
+}
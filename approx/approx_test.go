@@ -1,7 +1,10 @@
 package approx
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 )
 
 type TestCase struct {
@@ -723,3 +726,729 @@ func BenchmarkLongPLongTapproxFindPigeon(b *testing.B) {
 		}
 	}
 }
+
+func TestResolveOverlaps(t *testing.T) {
+	matches, _ := ApproxFind("ACATCC", "GATTACATATATGCATCT", 2, DefaultOptions)
+	expected := map[OverlapPolicy][]Match{
+		KeepAll:        matches,
+		BestPerEnd:     matches,
+		BestPerStart:   []Match{Match{4, 10, 2}, Match{8, 14, 2}, Match{12, 18, 2}},
+		NonOverlapping: []Match{Match{4, 10, 2}, Match{12, 18, 2}},
+		Cluster:        []Match{Match{4, 10, 2}},
+	}
+	for policy, exp := range expected {
+		resolved, err := ResolveOverlaps(matches, policy)
+		if err != nil {
+			t.Errorf("ResolveOverlaps returned an error for policy %d: %v", policy, err)
+		}
+		checkMatches(TestCase{Description: fmt.Sprintf("Overlap policy %d", policy), Expected: exp}, resolved, t)
+	}
+	if _, err := ResolveOverlaps(matches, OverlapPolicy(-1)); err == nil {
+		t.Errorf("Expected an error for an unknown overlap policy")
+	}
+}
+
+func TestApproxFindTopK(t *testing.T) {
+	for _, tCase := range EditTestCases {
+		all, _ := ApproxFind(tCase.Pattern, tCase.Text, tCase.MaxDist, DefaultOptions)
+		sortMatches(all)
+		// Asking for every match should find every match
+		top, err := ApproxFindTopK(tCase.Pattern, tCase.Text, len(all), tCase.MaxDist, DefaultOptions)
+		if err != nil {
+			t.Errorf("ApproxFindTopK returned an error for %s: %v", tCase.Description, err)
+		}
+		sortMatches(top)
+		checkMatches(TestCase{Description: tCase.Description, Expected: all}, top, t)
+
+		// The best match should have the lowest distance
+		best, err := ApproxFindBest(tCase.Pattern, tCase.Text, tCase.MaxDist, DefaultOptions)
+		if err != nil {
+			t.Errorf("ApproxFindBest returned an error for %s: %v", tCase.Description, err)
+		}
+		expected, _ := BestMatch(all)
+		if best != expected {
+			t.Errorf("Bad best match: %s\n Found: %v\n Expected %v\n", tCase.Description, best, expected)
+		}
+	}
+	top, _ := ApproxFindTopK("ACATCC", "GATTACATATATGCATCT", 2, 2, DefaultOptions)
+	checkMatches(TestCase{Description: "Top 2 of Double mm", Expected: []Match{Match{4, 10, 2}, Match{4, 9, 2}}}, top, t)
+	if best, _ := ApproxFindBest("ACATCC", "GATTACATATATGCATCT", 2, DefaultOptions); best != (Match{4, 10, 2}) {
+		t.Errorf("Bad best match of Double mm: %v, expected {4 10 2}", best)
+	}
+	if _, err := ApproxFindBest("GATTACA", "CCCCCCCCCC", 1, DefaultOptions); err == nil {
+		t.Errorf("Expected an error when there is no best match")
+	}
+
+	// Several end cells of the last column trace back to {0 2 0}, which must
+	// only take up one of the k places
+	op := DefaultOptions
+	op.EndGaps = Overlap
+	top, _ = ApproxFindTopK("AGCGGCC", "GC", 2, 2, op)
+	checkMatches(TestCase{Description: "Top 2 distinct overlaps", Expected: []Match{Match{0, 2, 0}, Match{0, 2, 1}}}, top, t)
+}
+
+func TestApproxFindAlignmentsCoOptimal(t *testing.T) {
+	op := DefaultOptions
+	alignments, _ := ApproxFindAlignments("GATTACA", "GATTTACA", 1, op)
+	if len(alignments) != 1 || string(alignments[0].Ops) != "==I=====" {
+		t.Errorf("Expected a single alignment with the insertion shifted left, found %v", alignments)
+	}
+
+	// Every placement of the extra T in the run is equally good
+	op.MaxCoOptimal = 10
+	alignments, _ = ApproxFindAlignments("GATTACA", "GATTTACA", 1, op)
+	expected := []string{"==I=====", "===I====", "====I==="}
+	if len(alignments) != len(expected) {
+		t.Fatalf("Expected %d co-optimal alignments, found %v", len(expected), alignments)
+	}
+	for i, a := range alignments {
+		if a.Match != (Match{0, 8, 1}) || string(a.Ops) != expected[i] {
+			t.Errorf("Bad co-optimal alignment %d: %v %s, expected %s", i, a.Match, string(a.Ops), expected[i])
+		}
+	}
+
+	// The cap is honored
+	op.MaxCoOptimal = 2
+	alignments, _ = ApproxFindAlignments("GATTACA", "GATTTACA", 1, op)
+	if len(alignments) != 2 {
+		t.Errorf("Expected the cap of 2 alignments, found %v", alignments)
+	}
+
+	// Alternative starts show up as extra matches
+	op.MaxCoOptimal = 10
+	matches, _ := ApproxFind("ACATCC", "GATTACATATATGCATCT", 2, op)
+	checkMatches(TestCase{
+		Description: "Co-optimal starts",
+		Expected: []Match{
+			Match{4, 8, 2}, Match{4, 9, 2}, Match{4, 10, 2}, Match{8, 14, 2},
+			Match{12, 17, 2}, Match{13, 17, 2}, Match{12, 18, 2}, Match{13, 18, 2},
+		},
+	}, matches, t)
+
+	// Every placement of the gap in the run shifts to the same alignment, which
+	// must not be walked for each of them
+	op.MaxCoOptimal = 2
+	op.Traceback = LeftAlignGaps
+	start := time.Now()
+	alignment, err := GlobalAlign("G"+strings.Repeat("A", 22)+"T", "G"+strings.Repeat("A", 30)+"T", op)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Co-optimal alignments with shifted gaps took %v", elapsed)
+	}
+	if err != nil || string(alignment.Ops) != "="+strings.Repeat("I", 8)+strings.Repeat("=", 23) {
+		t.Errorf("Bad alignment with shifted gaps: %s %v", string(alignment.Ops), err)
+	}
+	start = time.Now()
+	ApproxFindAlignments("G"+strings.Repeat("A", 22)+"T", "CG"+strings.Repeat("A", 30)+"TC", 8, op)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Co-optimal alignments with shifted gaps took %v", elapsed)
+	}
+}
+
+func TestTracebackPolicy(t *testing.T) {
+	cases := []struct {
+		Pattern  string
+		Text     string
+		Policy   TracebackPolicy
+		Expected string
+	}{
+		{"GATTACA", "GATTTACA", PreferDiagonal, "==I====="},
+		{"GATTACA", "GATTTACA", LeftAlignGaps, "==I====="},
+		{"GATTACA", "GATTTACA", RightAlignGaps, "====I==="},
+		{"GACACT", "GACT", LeftAlignGaps, "=DD==="},
+		{"GACACT", "GACT", RightAlignGaps, "===DD="},
+		{"GACACT", "AAGACTAA", RightAlignGaps, "===DD="},
+	}
+	for _, c := range cases {
+		op := DefaultOptions
+		op.Traceback = c.Policy
+		alignments, _ := ApproxFindAlignments(c.Pattern, c.Text, 2, op)
+		best := alignments[0]
+		for _, a := range alignments {
+			if betterMatch(a.Match, best.Match) {
+				best = a
+			}
+		}
+		if string(best.Ops) != c.Expected {
+			t.Errorf("Bad traceback for %s in %s with policy %d: found %s, expected %s",
+				c.Pattern, c.Text, c.Policy, string(best.Ops), c.Expected)
+		}
+	}
+}
+
+func TestLocalAlign(t *testing.T) {
+	// A partial adapter hanging off the end of a read
+	alignments, err := LocalAlign("AGATCGGAAGAGC", "ACGTACGTTTGCAAGATCGGA", 18, DefaultScoreOptions)
+	if err != nil {
+		t.Fatalf("LocalAlign returned an error: %v", err)
+	}
+	if len(alignments) != 3 {
+		t.Fatalf("Expected 3 local alignments, found %v", alignments)
+	}
+	best := alignments[2]
+	if best.Match != (Match{13, 21, 0}) || best.PatternStart != 0 || best.PatternEnd != 8 ||
+		best.Score != 24 || string(best.Ops) != "========" {
+		t.Errorf("Bad local alignment: %v", best)
+	}
+
+	// A mismatch inside the adapter
+	alignments, _ = LocalAlign("AGATCGGAAGAGC", "ACGTACGTTTGCAAGATCTGAAGA", 27, DefaultScoreOptions)
+	if len(alignments) != 1 || alignments[0].Match != (Match{13, 24, 1}) || string(alignments[0].Ops) != "=====X=====" {
+		t.Errorf("Bad local alignment with a mismatch: %v", alignments)
+	}
+
+	if _, err := LocalAlign("AGATCGGAAGAGC", "ACGT", 0, DefaultScoreOptions); err == nil {
+		t.Errorf("Expected an error for a minimum score of 0")
+	}
+}
+
+func TestDistance(t *testing.T) {
+	costly := DefaultOptions
+	costly.DelCost = 2
+	cases := []struct {
+		A        string
+		B        string
+		Op       Options
+		Expected int
+	}{
+		{"kitten", "sitting", DefaultOptions, 3},
+		{"GATTACA", "GATTACA", DefaultOptions, 0},
+		{"", "abc", DefaultOptions, 3},
+		{"abc", "", DefaultOptions, 3},
+		{"", "", DefaultOptions, 0},
+		{"GATTACA", "ATTAC", DefaultOptions, 2},
+		{"GATTACA", "ATTAC", costly, 4},
+	}
+	for _, c := range cases {
+		if d := Distance(c.A, c.B, c.Op); d != c.Expected {
+			t.Errorf("Bad distance between %q and %q: found %d, expected %d", c.A, c.B, d, c.Expected)
+		}
+	}
+}
+
+func TestGlobalAlign(t *testing.T) {
+	alignment, err := GlobalAlign("perl", "pearl", DefaultOptions)
+	if err != nil {
+		t.Fatalf("GlobalAlign returned an error: %v", err)
+	}
+	if alignment.Match != (Match{0, 5, 1}) || string(alignment.Ops) != "==I==" {
+		t.Errorf("Bad global alignment: %v %s", alignment.Match, string(alignment.Ops))
+	}
+
+	// Unlike ApproxFind, the ends of the text are not free
+	alignment, _ = GlobalAlign("ATTAC", "GATTACA", DefaultOptions)
+	if alignment.Match != (Match{0, 7, 2}) || string(alignment.Ops) != "I=====I" {
+		t.Errorf("Bad global alignment: %v %s", alignment.Match, string(alignment.Ops))
+	}
+
+	alignment, _ = GlobalAlign("", "AC", DefaultOptions)
+	if alignment.Match != (Match{0, 2, 2}) || string(alignment.Ops) != "II" {
+		t.Errorf("Bad global alignment against an empty pattern: %v %s", alignment.Match, string(alignment.Ops))
+	}
+
+	alignment, err = GlobalAlign("", "", DefaultOptions)
+	if err != nil || alignment.Match != (Match{0, 0, 0}) || len(alignment.Ops) != 0 {
+		t.Errorf("Bad global alignment of two empty strings: %v %s %v", alignment.Match, string(alignment.Ops), err)
+	}
+}
+
+func TestEndGaps(t *testing.T) {
+	cases := []struct {
+		Pattern  string
+		Text     string
+		Ends     EndGaps
+		Expected []Alignment
+	}{
+		{
+			// An adapter partly read at the end of a read
+			"AGATCGGAAGAGC", "ACGTACGTTTGCAAGATCGGA", SemiGlobal | FreePatternSuffix,
+			[]Alignment{
+				Alignment{Match: Match{20, 21, 0}, PatternStart: 0, PatternEnd: 1},
+				Alignment{Match: Match{13, 21, 0}, PatternStart: 0, PatternEnd: 8},
+			},
+		},
+		{
+			// The overlap of two paired reads
+			"GGATCCAAAT", "CCAAATGCTT", Overlap,
+			[]Alignment{Alignment{Match: Match{0, 6, 0}, PatternStart: 4, PatternEnd: 10}},
+		},
+		{
+			// The text contained in the pattern
+			"TTTTACGTTTT", "ACGT", FreePatternPrefix | FreePatternSuffix,
+			[]Alignment{Alignment{Match: Match{0, 4, 0}, PatternStart: 4, PatternEnd: 8}},
+		},
+		{
+			// Without free pattern ends the pattern must be found whole
+			"AGATCGGAAGAGC", "ACGTACGTTTGCAAGATCGGA", SemiGlobal, []Alignment{},
+		},
+	}
+	for _, c := range cases {
+		op := DefaultOptions
+		op.EndGaps = c.Ends
+		alignments, _ := ApproxFindAlignments(c.Pattern, c.Text, 0, op)
+		if len(alignments) != len(c.Expected) {
+			t.Errorf("Bad number of alignments for %s in %s: found %v, expected %v", c.Pattern, c.Text, alignments, c.Expected)
+			continue
+		}
+		for i, a := range alignments {
+			e := c.Expected[i]
+			if a.Match != e.Match || a.PatternStart != e.PatternStart || a.PatternEnd != e.PatternEnd {
+				t.Errorf("Bad alignment for %s in %s: found %v, expected %v", c.Pattern, c.Text, a, e)
+			}
+		}
+	}
+}
+
+func TestTransposition(t *testing.T) {
+	op := DefaultOptions
+	op.TransCost = 1
+	if d := Distance("teh", "the", op); d != 1 {
+		t.Errorf("Expected a transposition to cost 1, found %d", d)
+	}
+	if d := Distance("teh", "the", DefaultOptions); d != 2 {
+		t.Errorf("Expected a transposition to cost 2 without TransCost, found %d", d)
+	}
+	// Optimal string alignment doesn't edit a transposed pair again
+	if d := Distance("CA", "ABC", op); d != 3 {
+		t.Errorf("Expected the optimal string alignment distance of 3, found %d", d)
+	}
+
+	alignments, _ := ApproxFindAlignments("the", "teh quick", 1, op)
+	found := false
+	for _, a := range alignments {
+		if a.Match == (Match{0, 3, 1}) && string(a.Ops) == "=T" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected the transposed match of \"the\" in \"teh quick\", found %v", alignments)
+	}
+}
+
+func TestHammingFind(t *testing.T) {
+	for _, tCase := range ExactTestCases {
+		matches, _ := HammingFind(tCase.Pattern, tCase.Text, tCase.MaxDist, DefaultOptions)
+		checkMatches(tCase, matches, t)
+	}
+	for _, tCase := range MismatchTestCases {
+		matches, _ := HammingFind(tCase.Pattern, tCase.Text, tCase.MaxDist, DefaultOptions)
+		checkMatches(tCase, matches, t)
+	}
+
+	// Patterns too long for a single word fall back to comparing at each offset
+	pattern := "TCGTCGTAGCGTCAGATGTGTATAAGAGACAGCTGTTCTCTC"
+	text := "ACTCANTTATGCATGACTGGCAACAGTCATGTATAACTCGTCGAAGCGTCAGATGTGTATAAGAGACAGCTGTTCTCTCTCATCCC"
+	matches, _ := HammingFind(pattern, text, 3, DefaultOptions)
+	checkMatches(TestCase{
+		Description: "Long pattern Hamming search",
+		Expected:    naiveFuzzyFind(pattern, text, 3),
+	}, matches, t)
+}
+
+func TestBitapFind(t *testing.T) {
+	// Plain patterns find the same ends and distances as ApproxFind
+	for _, tCase := range EditTestCases {
+		matches, err := BitapFind(tCase.Pattern, tCase.Text, tCase.MaxDist, DefaultOptions)
+		if err != nil {
+			t.Errorf("BitapFind returned an error for %s: %v", tCase.Description, err)
+		}
+		expected := []Match{}
+		for _, m := range tCase.Expected {
+			if m.End > 0 {
+				expected = append(expected, m)
+			}
+		}
+		if len(matches) != len(expected) {
+			t.Errorf("Bad number of matches for %s: found %v, expected %v", tCase.Description, matches, expected)
+			continue
+		}
+		for i, m := range matches {
+			if m.End != expected[i].End || m.Dist != expected[i].Dist {
+				t.Errorf("Bad match for %s: found %v, expected %v", tCase.Description, m, expected[i])
+			}
+			if d := Distance(tCase.Pattern, string([]rune(tCase.Text)[m.Start:m.End]), DefaultOptions); d != m.Dist {
+				t.Errorf("Bad start for %s: %v has distance %d", tCase.Description, m, d)
+			}
+		}
+	}
+
+	cases := []TestCase{
+		TestCase{
+			Pattern: "AC[GT]T", Text: "xxACGTxxACTTxxACATxx", Description: "Character class",
+			Expected: []Match{Match{2, 6, 0}, Match{8, 12, 0}},
+		},
+		TestCase{
+			Pattern: "GAT[^C]ACA", Text: "GATTACAGATCACA", Description: "Negated class",
+			Expected: []Match{Match{0, 7, 0}},
+		},
+		TestCase{
+			Pattern: "A.T", Text: "AGTxACT", Description: "Wildcard",
+			Expected: []Match{Match{0, 3, 0}, Match{4, 7, 0}},
+		},
+		TestCase{
+			Pattern: "ACG?T", Text: "ACTxACGT", Description: "Optional rune",
+			Expected: []Match{Match{0, 3, 0}, Match{4, 8, 0}},
+		},
+		TestCase{
+			Pattern: "AC[GT]T", Text: "xxACATxx", Description: "Character class with a substitution", MaxDist: 1,
+			Expected: []Match{Match{2, 6, 1}},
+		},
+		TestCase{
+			Pattern: "G?CAT", Text: "AT", Description: "Deleting the rune after a leading optional one", MaxDist: 1,
+			Expected: []Match{Match{0, 2, 1}},
+		},
+		TestCase{
+			Pattern: "A?GAC", Text: "ATAT", Description: "Deleting runes after a leading optional one", MaxDist: 2,
+			Expected: []Match{Match{0, 1, 2}, Match{0, 2, 2}, Match{2, 3, 2}, Match{2, 4, 2}},
+		},
+	}
+	for _, tCase := range cases {
+		matches, _ := BitapFind(tCase.Pattern, tCase.Text, tCase.MaxDist, DefaultOptions)
+		checkMatches(tCase, matches, t)
+	}
+
+	// Classes fold case like literals
+	matches, _ := BitapFind("a[ac]", "xAC", 0, CaseInsensitiveOptions)
+	checkMatches(TestCase{Description: "Case insensitive class", Expected: []Match{Match{1, 3, 0}}}, matches, t)
+	matches, _ = BitapFind("A[^c]G", "xACGxATGx", 0, CaseInsensitiveOptions)
+	checkMatches(TestCase{Description: "Case insensitive negated class", Expected: []Match{Match{5, 8, 0}}}, matches, t)
+
+	for _, bad := range []string{"", "?A", "AC[GT", "A[]C", "AC\\", "A[T-A]"} {
+		if _, err := CompileBitap(bad); err == nil {
+			t.Errorf("Expected an error compiling %q", bad)
+		}
+	}
+}
+
+func TestWeights(t *testing.T) {
+	// The last three runes of the primer must match, the first two are free
+	// to change
+	primer := "NNGATTACA"
+	op := DefaultOptions
+	op.Weights = UniformWeights(primer, op)
+	for i := 0; i < 2; i++ {
+		op.Weights[i] = PositionWeight{Sub: 0, Del: 1, Ins: 1}
+	}
+	for i := len(op.Weights) - 3; i < len(op.Weights); i++ {
+		op.Weights[i].MustMatch = true
+	}
+
+	cases := []TestCase{
+		TestCase{
+			Pattern: primer, Text: "xxCTGATTACAxx", Description: "Free positions", MaxDist: 0,
+			Expected: []Match{Match{2, 11, 0}},
+		},
+		TestCase{
+			Pattern: primer, Text: "xxCTGATTAGAxx", Description: "Mismatch in a must match position", MaxDist: 1,
+			Expected: []Match{},
+		},
+		TestCase{
+			Pattern: primer, Text: "xxCTGACTACAxx", Description: "Mismatch in a normal position", MaxDist: 1,
+			Expected: []Match{Match{2, 11, 1}},
+		},
+	}
+	for _, tCase := range cases {
+		matches, err := ApproxFind(tCase.Pattern, tCase.Text, tCase.MaxDist, op)
+		if err != nil {
+			t.Errorf("ApproxFind returned an error for %s: %v", tCase.Description, err)
+		}
+		checkMatches(tCase, matches, t)
+	}
+
+	if _, err := ApproxFind("GATTACA", "GATTACA", 1, op); err == nil {
+		t.Errorf("Expected an error for the wrong number of weights")
+	}
+	short := DefaultOptions
+	short.Weights = UniformWeights("AB", short)
+	if d := Distance("ABC", "ABC", short); d != MaxInt {
+		t.Errorf("Bad Distance with the wrong number of weights: %d, expected MaxInt", d)
+	}
+	op.Weights = UniformWeights("GATTACA", op)
+	op.Weights[5].MustMatch = true
+	if d := Distance("GATTACA", "GATTAGA", op); d != MaxInt {
+		t.Errorf("Bad distance with a must match mismatch: %d", d)
+	}
+	// An expensive substitution is cheaper as an insertion and a deletion
+	op.Weights[5] = PositionWeight{Sub: 5, Del: 1, Ins: 1}
+	if d := Distance("GATTACA", "GATTAGA", op); d != 2 {
+		t.Errorf("Bad distance with a heavy substitution: %d, expected 2", d)
+	}
+}
+
+func TestApproxFindQual(t *testing.T) {
+	quals, err := DecodePhred("IIIIII+IIIII")
+	if err != nil {
+		t.Fatalf("DecodePhred returned an error: %v", err)
+	}
+	// The mismatch at the Q10 base costs 0.9
+	matches, err := ApproxFindQual("GATTACA", "xxGATTGCAxxx", quals, 0.9, DefaultOptions)
+	if err != nil {
+		t.Fatalf("ApproxFindQual returned an error: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Match != (Match{2, 9, 1}) || matches[0].Weighted != 0.9 {
+		t.Errorf("Bad matches for a low quality mismatch: %v", matches)
+	}
+	// The same mismatch at a Q40 base is over the budget
+	for i := range quals {
+		quals[i] = 40
+	}
+	matches, _ = ApproxFindQual("GATTACA", "xxGATTGCAxxx", quals, 0.9, DefaultOptions)
+	if len(matches) != 0 {
+		t.Errorf("Expected no matches for a high quality mismatch: %v", matches)
+	}
+
+	if _, err := ApproxFindQual("GATTACA", "GATTACA", quals, 1, DefaultOptions); err == nil {
+		t.Errorf("Expected an error for the wrong number of qualities")
+	}
+	if _, err := DecodePhred("II I"); err == nil {
+		t.Errorf("Expected an error for a bad quality character")
+	}
+}
+
+// expandSharpS is a Normalizer that spells out "ß" as "ss"
+func expandSharpS(s string, emit func(segment string, size int)) {
+	for _, r := range s {
+		if r == 'ß' {
+			emit("ss", len(string(r)))
+		} else {
+			emit(string(r), len(string(r)))
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	expanded := DefaultOptions
+	expanded.Normalize = expandSharpS
+	cases := []struct {
+		TestCase
+		Options Options
+	}{
+		{TestCase{Pattern: "GATTACA", Text: "xxgAttaCaxx", Description: "Case folding",
+			Expected: []Match{Match{2, 9, 0}}}, CaseInsensitiveOptions},
+		{TestCase{Pattern: "strasse", Text: "die Straße", Description: "Offsets in the original text",
+			Expected: []Match{Match{4, 10, 1}}, MaxDist: 1}, expanded},
+		{TestCase{Pattern: "s", Text: "aßb", Description: "Part of an expanded rune",
+			Expected: []Match{Match{1, 2, 0}}}, expanded},
+	}
+	for _, tCase := range cases {
+		matches, err := ApproxFind(tCase.Pattern, tCase.Text, tCase.MaxDist, tCase.Options)
+		if err != nil {
+			t.Errorf("ApproxFind returned an error for %s: %v", tCase.Description, err)
+		}
+		checkMatches(tCase.TestCase, matches, t)
+	}
+
+	if matches, err := ApproxFindTopK("s", "aßb", 2, 0, expanded); err != nil || len(matches) != 1 || matches[0] != (Match{1, 2, 0}) {
+		t.Errorf("Bad top matches in an expanded rune: %v %v", matches, err)
+	}
+	if d := Distance("Maße", "masse", expanded); d != 1 {
+		t.Errorf("Bad normalized distance: %d, expected 1", d)
+	}
+}
+
+func TestGraphemes(t *testing.T) {
+	op := DefaultOptions
+	op.Graphemes = true
+	thumbs := "\U0001F44D\U0001F3FD" // thumbs up with a skin tone
+	family := "\U0001F468\u200D\U0001F469\u200D\U0001F467"
+	cases := []TestCase{
+		TestCase{
+			Pattern: "ok" + thumbs, Text: "ok\U0001F44D\U0001F3FBxx", MaxDist: 1, Description: "Skin tone is one edit",
+			Expected: []Match{Match{0, 2, 1}, Match{0, 4, 1}},
+		},
+		TestCase{
+			Pattern: "a" + family + "b", Text: "xxa" + family + "bxx", Description: "ZWJ sequence",
+			Expected: []Match{Match{2, 9, 0}},
+		},
+		TestCase{
+			Pattern: "cafe", Text: "cafe\u0301!", MaxDist: 1, Description: "End doesn't split an accent",
+			Expected: []Match{Match{0, 3, 1}, Match{0, 5, 1}},
+		},
+		TestCase{
+			Pattern: "\U0001F1EB\U0001F1F7", Text: "\U0001F1E9\U0001F1EA\U0001F1EB\U0001F1F7", Description: "Flags pair up",
+			Expected: []Match{Match{2, 4, 0}},
+		},
+	}
+	for _, tCase := range cases {
+		matches, err := ApproxFind(tCase.Pattern, tCase.Text, tCase.MaxDist, op)
+		if err != nil {
+			t.Errorf("ApproxFind returned an error for %s: %v", tCase.Description, err)
+		}
+		checkMatches(tCase, matches, t)
+	}
+
+	if d := Distance(family, "\U0001F468", op); d != 1 {
+		t.Errorf("Bad distance between clusters: %d, expected 1", d)
+	}
+	if d := Distance(family, "\U0001F468", DefaultOptions); d != 4 {
+		t.Errorf("Bad distance between runes: %d, expected 4", d)
+	}
+}
+
+func TestFindSeq(t *testing.T) {
+	words := strings.Fields("the quick brown fox jumps over the lazy dog")
+	op := SeqOptions[string]{Options: DefaultOptions}
+	matches, err := FindSeq(strings.Fields("brown fax jumps"), words, 1, op)
+	if err != nil {
+		t.Fatalf("FindSeq returned an error: %v", err)
+	}
+	checkMatches(TestCase{Description: "Words", Expected: []Match{Match{2, 5, 1}}}, matches, t)
+
+	// Equal can make tokens match without being identical
+	op.Equal = strings.EqualFold
+	matches, _ = FindSeq(strings.Fields("LAZY Dog"), words, 0, op)
+	checkMatches(TestCase{Description: "Equal function", Expected: []Match{Match{7, 9, 0}}}, matches, t)
+
+	codons := []string{"ATG", "GCC", "TTA", "GGA", "TAA"}
+	alignments, _ := FindSeqAlignments([]string{"GCC", "GGA"}, codons, 1, SeqOptions[string]{Options: DefaultOptions})
+	if len(alignments) != 3 || alignments[1].Match != (Match{1, 3, 1}) || string(alignments[1].Ops) != "=X" {
+		t.Errorf("Bad codon alignments: %v", alignments)
+	}
+
+	if _, err := FindSeq([]int{}, []int{1, 2}, 1, SeqOptions[int]{Options: DefaultOptions}); err == nil {
+		t.Errorf("Expected an error for an empty pattern")
+	}
+}
+
+func TestFindPhrase(t *testing.T) {
+	text := "It was the best of times, it was the worst of times."
+	op := PhraseOptions{Options: DefaultOptions, WordMaxE: 1}
+	cases := []TestCase{
+		TestCase{
+			Pattern: "the bst of tims", Text: text, Description: "Misspelled words",
+			Expected: []Match{Match{7, 24, 0}},
+		},
+		TestCase{
+			Pattern: "the worst times", Text: text, MaxDist: 1, Description: "Missing word",
+			Expected: []Match{Match{33, 51, 1}},
+		},
+		TestCase{
+			Pattern: "was the very worst", Text: text, MaxDist: 1, Description: "Extra word",
+			Expected: []Match{Match{29, 42, 1}},
+		},
+	}
+	for _, tCase := range cases {
+		matches, err := FindPhrase(tCase.Pattern, tCase.Text, tCase.MaxDist, op)
+		if err != nil {
+			t.Errorf("FindPhrase returned an error for %s: %v", tCase.Description, err)
+		}
+		matches, _ = ResolveOverlaps(matches, NonOverlapping)
+		checkMatches(tCase, matches, t)
+	}
+
+	if _, err := FindPhrase("...", text, 1, op); err == nil {
+		t.Errorf("Expected an error for a phrase with no words")
+	}
+}
+
+func TestTranslatedFind(t *testing.T) {
+	if aas := StandardCode.Translate("ATGgcuTAANNNAC"); aas != "MA*X" {
+		t.Errorf("Bad translation: %s", aas)
+	}
+	if rc := ReverseComplement("AACGTNr"); rc != "yNACGTT" {
+		t.Errorf("Bad reverse complement: %s", rc)
+	}
+
+	// MKWV on the forward strand in frame 1, and MKWV with a substitution on the
+	// reverse strand
+	forward := "C" + "ATGAAATGGGTT" + "CC"
+	reverse := ReverseComplement("ATGAAATGCGTT")
+	matches, err := TranslatedFind("MKWV", forward+reverse, 1, nil, DefaultOptions)
+	if err != nil {
+		t.Fatalf("TranslatedFind returned an error: %v", err)
+	}
+	best := map[Strand]TranslatedMatch{}
+	for _, m := range matches {
+		if b, ok := best[m.Strand]; !ok || betterMatch(m.Match, b.Match) {
+			best[m.Strand] = m
+		}
+	}
+	if m := best[Forward]; m.Match != (Match{1, 13, 0}) || m.Frame != 1 {
+		t.Errorf("Bad forward match: %v", m)
+	}
+	if m := best[Reverse]; m.Match != (Match{15, 27, 1}) || m.Frame != 0 {
+		t.Errorf("Bad reverse match: %v", m)
+	}
+
+	if _, err := NewGeneticCode("FFLL"); err == nil {
+		t.Errorf("Expected an error for a short genetic code")
+	}
+}
+
+func TestMaxErrorRate(t *testing.T) {
+	// A rate is the same as the maxE it allows the whole pattern
+	op := DefaultOptions
+	op.MaxErrorRate = 0.15
+	for _, text := range []string{"xxGATTACAxx", "xxGATCACAxx", "xxGACCACAxx", "GATTAC"} {
+		expected, _ := ApproxFind("GATTACA", text, 1, DefaultOptions)
+		matches, err := ApproxFind("GATTACA", text, 7, op)
+		if err != nil {
+			t.Errorf("ApproxFind returned an error for %s: %v", text, err)
+		}
+		checkMatches(TestCase{Pattern: "GATTACA", Text: text, Description: "Rate as maxE", Expected: expected}, matches, t)
+		top, _ := ApproxFindTopK("GATTACA", text, 1, 7, op)
+		if len(top) != min(len(expected), 1) {
+			t.Errorf("Bad top match for %s: %v, expected one of %v", text, top, expected)
+		}
+	}
+
+	// A partial adapter at the end of a read is allowed errors for its overlap
+	adapter, read := "AGATCGGAAG", "CCCCCCAGTTC"
+	cases := []struct {
+		Rate  float64
+		Found bool
+	}{
+		{0.2, true},
+		{0.1, false},
+	}
+	for _, c := range cases {
+		op := DefaultOptions
+		op.EndGaps = SemiGlobal | FreePatternSuffix
+		op.MaxErrorRate = c.Rate
+		alignments, err := ApproxFindAlignments(adapter, read, len(adapter), op)
+		if err != nil {
+			t.Fatalf("ApproxFindAlignments returned an error: %v", err)
+		}
+		found := false
+		for _, a := range alignments {
+			if a.Dist > int(c.Rate*float64(a.PatternEnd-a.PatternStart)) {
+				t.Errorf("Alignment %+v has too many errors for a rate of %v", a, c.Rate)
+			}
+			if a.Start == 6 && a.End == 11 && a.PatternEnd == 5 {
+				found = true
+			}
+		}
+		if found != c.Found {
+			t.Errorf("Bad partial overlap at a rate of %v: found %v, expected %v in %+v", c.Rate, found, c.Found, alignments)
+		}
+	}
+
+	// Distance ignores the rate
+	if d := Distance("GATTACA", "GACCACA", op); d != 2 {
+		t.Errorf("Bad Distance with a rate: %d, expected 2", d)
+	}
+}
+
+func TestFinder(t *testing.T) {
+	f, err := Compile("GATTACA", 1, DefaultOptions)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	texts := []string{"xxGATTACAxxxxGATCACAxx", "GATACA", "xGATTxCAxxxxxxxxxxxxxxGATTACA", "AGATTACAG"}
+	for _, text := range texts {
+		matches, err := f.Find(text)
+		if err != nil {
+			t.Fatalf("Find failed in %s: %v", text, err)
+		}
+		expected, _ := ApproxFind("GATTACA", text, 1, DefaultOptions)
+		checkMatches(TestCase{Pattern: "GATTACA", Text: text, Description: "Finder in " + text, Expected: expected}, matches, t)
+	}
+
+	// The longest text so far fits in the rows that are already there
+	row := &f.c.matrix[0][0]
+	if _, err := f.Find("GATTACAGATTACA"); err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if &f.c.matrix[0][0] != row {
+		t.Errorf("Finder didn't reuse its matrix")
+	}
+}
@@ -0,0 +1,359 @@
+// Package demux splits pooled reads into samples by the barcodes in them,
+// allowing a few errors in each barcode.
+package demux
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sstadick/fuzzyfind/approx"
+	"github.com/sstadick/fuzzyfind/approx/seqio"
+)
+
+// Names of the outputs for reads that were not assigned to a sample
+const (
+	AmbiguousName  = "ambiguous"
+	UnassignedName = "unassigned"
+)
+
+// A Sample is a sample in a pool and the barcode that marks its reads
+type Sample struct {
+	Name    string
+	Barcode string
+}
+
+// ReadSheet reads a barcode sheet: one sample per line, its name and barcode
+// separated by a comma or whitespace. Blank lines and lines starting with # are
+// skipped, as is a first line whose barcode column is "barcode".
+func ReadSheet(r io.Reader) ([]Sample, error) {
+	samples := []Sample{}
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(c rune) bool {
+			return c == ',' || c == ' ' || c == '\t'
+		})
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("sheet line %d: expected a name and a barcode, got %q", lineNo, line)
+		}
+		if lineNo == 1 && strings.EqualFold(fields[1], "barcode") {
+			continue
+		}
+		samples = append(samples, Sample{Name: fields[0], Barcode: strings.ToUpper(fields[1])})
+	}
+	return samples, scanner.Err()
+}
+
+// Location is where in a read the barcode is looked for
+type Location int
+
+const (
+	// ReadStart barcodes start the read
+	ReadStart Location = iota
+	// Anywhere barcodes may be anywhere in the read
+	Anywhere
+	// AfterLinker barcodes start right after the best match of Options.Linker
+	AfterLinker
+)
+
+// Options are the options for a Demuxer
+type Options struct {
+	// MaxE is the most errors allowed in a barcode
+	MaxE int
+	// Location is where the barcode is
+	Location Location
+	// Linker is the sequence the barcode follows for AfterLinker
+	Linker string
+	// LinkerMaxE is the most errors allowed in the linker
+	LinkerMaxE int
+	// Trim removes the barcode, and everything before it, from assigned reads
+	Trim bool
+	// Match gives the costs and how bases are compared
+	Match approx.Options
+}
+
+// DefaultOptions find barcodes at the start of reads with up to one error
+var DefaultOptions Options = Options{
+	MaxE:     1,
+	Location: ReadStart,
+	Match:    approx.DefaultOptions,
+}
+
+// Status says whether a read was assigned to a sample
+type Status int
+
+const (
+	// Assigned reads have one barcode that matched better than all the others
+	Assigned Status = iota
+	// Ambiguous reads have several barcodes that matched equally well
+	Ambiguous
+	// Unassigned reads have no barcode within MaxE, or no linker for AfterLinker
+	Unassigned
+)
+
+// String returns the name of the status
+func (s Status) String() string {
+	switch s {
+	case Assigned:
+		return "assigned"
+	case Ambiguous:
+		return AmbiguousName
+	}
+	return UnassignedName
+}
+
+// An Assignment is the sample a read was assigned to
+type Assignment struct {
+	Status Status
+	// Sample is the sample of an Assigned read, nil otherwise
+	Sample *Sample
+	// Match is where the barcode was in the read, for Assigned and Ambiguous reads
+	Match approx.Match
+}
+
+// A Demuxer assigns reads to samples. It reuses its search matrices between
+// reads, so it must not be used from several goroutines at once.
+type Demuxer struct {
+	samples  []Sample
+	barcodes []*approx.Finder
+	linker   *approx.Finder
+	op       Options
+}
+
+// NewDemuxer checks the samples and options and returns a Demuxer for them.
+// Sample names must be unique, usable as file names, and not ambiguous or
+// unassigned, and barcodes must be unique.
+func NewDemuxer(samples []Sample, op Options) (*Demuxer, error) {
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no samples to demultiplex")
+	} else if op.MaxE < 0 {
+		return nil, fmt.Errorf("maxE must be at least 0, got %d", op.MaxE)
+	} else if op.Location == AfterLinker && op.Linker == "" {
+		return nil, fmt.Errorf("no linker to find the barcodes after")
+	}
+	d := &Demuxer{samples: append([]Sample{}, samples...), op: op}
+	names := map[string]bool{}
+	barcodes := map[string]string{}
+	for _, s := range d.samples {
+		if s.Name == "" || strings.ContainsAny(s.Name, `/\`) || s.Name == AmbiguousName || s.Name == UnassignedName {
+			return nil, fmt.Errorf("bad sample name %q", s.Name)
+		} else if names[s.Name] {
+			return nil, fmt.Errorf("sample %s is listed twice", s.Name)
+		} else if other, ok := barcodes[s.Barcode]; ok {
+			return nil, fmt.Errorf("samples %s and %s have the same barcode %s", other, s.Name, s.Barcode)
+		}
+		names[s.Name], barcodes[s.Barcode] = true, s.Name
+
+		match := op.Match
+		if op.Location != Anywhere {
+			match.EndGaps = approx.FreeTextSuffix
+		}
+		f, err := approx.Compile(s.Barcode, op.MaxE, match)
+		if err != nil {
+			return nil, fmt.Errorf("sample %s: %v", s.Name, err)
+		}
+		d.barcodes = append(d.barcodes, f)
+	}
+	if op.Location == AfterLinker {
+		f, err := approx.Compile(op.Linker, op.LinkerMaxE, op.Match)
+		if err != nil {
+			return nil, fmt.Errorf("linker: %v", err)
+		}
+		d.linker = f
+	}
+	return d, nil
+}
+
+// Samples returns the samples reads are assigned to
+func (d *Demuxer) Samples() []Sample {
+	return d.samples
+}
+
+// best returns the best of matches, the lowest Dist and then the leftmost
+func best(matches []approx.Match) (approx.Match, bool) {
+	if len(matches) == 0 {
+		return approx.Match{}, false
+	}
+	b := matches[0]
+	for _, m := range matches[1:] {
+		if m.Dist < b.Dist || (m.Dist == b.Dist && m.Start < b.Start) {
+			b = m
+		}
+	}
+	return b, true
+}
+
+// Assign finds the barcode of a read. The read is assigned to a sample if its
+// barcode matches with fewer errors than any other, and is ambiguous if several
+// barcodes tie for the fewest.
+func (d *Demuxer) Assign(seq string) (Assignment, error) {
+	unassigned := Assignment{Status: Unassigned}
+	offset := 0
+	if d.linker != nil {
+		if seq == "" {
+			return unassigned, nil
+		}
+		matches, err := d.linker.Find(seq)
+		if err != nil {
+			return unassigned, fmt.Errorf("linker: %v", err)
+		}
+		m, ok := best(matches)
+		if !ok {
+			return unassigned, nil
+		}
+		offset = m.End
+	}
+	text := seq[seqio.ByteOffset(seq, offset):]
+	if text == "" {
+		return unassigned, nil
+	}
+
+	a := unassigned
+	for i, f := range d.barcodes {
+		matches, err := f.Find(text)
+		if err != nil {
+			return unassigned, fmt.Errorf("sample %s: %v", d.samples[i].Name, err)
+		}
+		m, ok := best(matches)
+		if !ok {
+			continue
+		}
+		m.Start, m.End = m.Start+offset, m.End+offset
+		switch {
+		case a.Status == Unassigned || m.Dist < a.Match.Dist:
+			a = Assignment{Status: Assigned, Sample: &d.samples[i], Match: m}
+		case m.Dist == a.Match.Dist:
+			a.Status, a.Sample = Ambiguous, nil
+		}
+	}
+	return a, nil
+}
+
+// Stats counts the reads assigned to each sample
+type Stats struct {
+	Reads      int
+	Samples    []Sample
+	Assigned   []int
+	Ambiguous  int
+	Unassigned int
+}
+
+// add counts one read
+func (s *Stats) add(a Assignment) {
+	s.Reads++
+	switch a.Status {
+	case Assigned:
+		for i := range s.Samples {
+			if s.Samples[i].Name == a.Sample.Name {
+				s.Assigned[i]++
+			}
+		}
+	case Ambiguous:
+		s.Ambiguous++
+	default:
+		s.Unassigned++
+	}
+}
+
+// Write writes the stats as TSV: each sample with its barcode, number of reads
+// and percentage of all reads, then the ambiguous and unassigned reads
+func (s *Stats) Write(w io.Writer) error {
+	percent := func(n int) float64 {
+		if s.Reads == 0 {
+			return 0
+		}
+		return 100 * float64(n) / float64(s.Reads)
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "sample\tbarcode\treads\tpercent\n")
+	for i, sample := range s.Samples {
+		fmt.Fprintf(bw, "%s\t%s\t%d\t%.2f\n", sample.Name, sample.Barcode, s.Assigned[i], percent(s.Assigned[i]))
+	}
+	fmt.Fprintf(bw, "%s\t-\t%d\t%.2f\n", AmbiguousName, s.Ambiguous, percent(s.Ambiguous))
+	fmt.Fprintf(bw, "%s\t-\t%d\t%.2f\n", UnassignedName, s.Unassigned, percent(s.Unassigned))
+	return bw.Flush()
+}
+
+// An Opener opens the output for the reads of a sample, or for the ambiguous or
+// unassigned reads
+type Opener func(name string) (io.WriteCloser, error)
+
+// DirOpener opens NAME.fastq in dir for each output, creating dir if needed
+func DirOpener(dir string) Opener {
+	return func(name string) (io.WriteCloser, error) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		return os.Create(filepath.Join(dir, name+".fastq"))
+	}
+}
+
+// output is an open output and the FASTQ writer on it
+type output struct {
+	closer io.Closer
+	w      *seqio.FastqWriter
+}
+
+// Demux assigns every read of r and writes it as FASTQ to the output of its
+// sample, or to the ambiguous or unassigned output. Outputs are only opened once
+// a read is written to them. With Options.Trim, assigned reads lose their barcode
+// and everything before it.
+func Demux(r seqio.Reader, d *Demuxer, open Opener) (*Stats, error) {
+	stats := &Stats{Samples: d.samples, Assigned: make([]int, len(d.samples))}
+	outputs := map[string]*output{}
+	err := demux(r, d, open, stats, outputs)
+	for _, o := range outputs {
+		if flushErr := o.w.Flush(); err == nil {
+			err = flushErr
+		}
+		if closeErr := o.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return stats, err
+}
+
+// demux does the work of Demux, leaving the outputs to be closed
+func demux(r seqio.Reader, d *Demuxer, open Opener, stats *Stats, outputs map[string]*output) error {
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		a, err := d.Assign(rec.Seq)
+		if err != nil {
+			return fmt.Errorf("%s: %v", rec.Name, err)
+		}
+		stats.add(a)
+
+		name := a.Status.String()
+		if a.Status == Assigned {
+			name = a.Sample.Name
+			if d.op.Trim {
+				rec = rec.Slice(a.Match.End, utf8.RuneCountInString(rec.Seq))
+			}
+		}
+		o, ok := outputs[name]
+		if !ok {
+			wc, err := open(name)
+			if err != nil {
+				return err
+			}
+			o = &output{closer: wc, w: seqio.NewFastqWriter(wc)}
+			outputs[name] = o
+		}
+		if err := o.w.Write(rec); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package demux
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sstadick/fuzzyfind/approx"
+	"github.com/sstadick/fuzzyfind/approx/seqio"
+)
+
+var testSamples = []Sample{{"s1", "AAAAAA"}, {"s2", "CCCCCC"}, {"s3", "AAAACC"}}
+
+func TestReadSheet(t *testing.T) {
+	input := "name,barcode\n# pool 1\ns1,acgtac\n\ns2\tTTGGCC\n"
+	samples, err := ReadSheet(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadSheet returned an error: %v", err)
+	}
+	expected := []Sample{{"s1", "ACGTAC"}, {"s2", "TTGGCC"}}
+	if len(samples) != len(expected) || samples[0] != expected[0] || samples[1] != expected[1] {
+		t.Errorf("Bad samples: %v, expected %v", samples, expected)
+	}
+	if _, err := ReadSheet(strings.NewReader("s1,ACGT,extra\n")); err == nil {
+		t.Errorf("Expected an error for a line with three fields")
+	}
+}
+
+func TestAssign(t *testing.T) {
+	cases := []struct {
+		Location Location
+		Seq      string
+		Status   Status
+		Sample   string
+	}{
+		{ReadStart, "AAAAAAGTGTGT", Assigned, "s1"},
+		{ReadStart, "CCCCGCGTGTGT", Assigned, "s2"},
+		// One error from both s1 and s3
+		{ReadStart, "AAAAACGTGTGT", Ambiguous, ""},
+		{ReadStart, "GTGTGTAAAAAA", Unassigned, ""},
+		{Anywhere, "GTGTGTAAAAAA", Assigned, "s1"},
+		{Anywhere, "GTGTGTGTGTGT", Unassigned, ""},
+		{AfterLinker, "GTGGATCCCCCCCGT", Assigned, "s2"},
+		{AfterLinker, "CCCCCCGATCGTGTGT", Unassigned, ""},
+		{AfterLinker, "GTGTGTGTGTGT", Unassigned, ""},
+		{AfterLinker, "GTGTGATC", Unassigned, ""},
+		{ReadStart, "", Unassigned, ""},
+	}
+	for _, c := range cases {
+		op := DefaultOptions
+		op.Location, op.Linker = c.Location, "GATC"
+		d, err := NewDemuxer(testSamples, op)
+		if err != nil {
+			t.Fatalf("NewDemuxer returned an error: %v", err)
+		}
+		a, err := d.Assign(c.Seq)
+		if err != nil {
+			t.Errorf("Assign returned an error for %s: %v", c.Seq, err)
+			continue
+		}
+		name := ""
+		if a.Sample != nil {
+			name = a.Sample.Name
+		}
+		if a.Status != c.Status || name != c.Sample {
+			t.Errorf("Bad assignment of %s: %v %q, expected %v %q", c.Seq, a.Status, name, c.Status, c.Sample)
+		}
+	}
+}
+
+func TestAssignOffsets(t *testing.T) {
+	op := DefaultOptions
+	op.Location, op.Linker = AfterLinker, "GATC"
+	d, err := NewDemuxer(testSamples, op)
+	if err != nil {
+		t.Fatalf("NewDemuxer returned an error: %v", err)
+	}
+	// Match offsets are in runes, and the ñ are two bytes each
+	a, err := d.Assign("ññGATCCCCCCCGT")
+	if err != nil {
+		t.Fatalf("Assign returned an error: %v", err)
+	}
+	if a.Status != Assigned || a.Sample.Name != "s2" || a.Match != (approx.Match{Start: 6, End: 12, Dist: 0}) {
+		t.Errorf("Bad assignment after a non-ASCII prefix: %v %v", a.Status, a.Match)
+	}
+}
+
+func TestNewDemuxerErrors(t *testing.T) {
+	bad := [][]Sample{
+		nil,
+		{{"", "ACGT"}},
+		{{"a/b", "ACGT"}},
+		{{AmbiguousName, "ACGT"}},
+		{{"s1", "ACGT"}, {"s1", "TTTT"}},
+		{{"s1", "ACGT"}, {"s2", "ACGT"}},
+		{{"s1", ""}},
+	}
+	for _, samples := range bad {
+		if _, err := NewDemuxer(samples, DefaultOptions); err == nil {
+			t.Errorf("Expected an error for %v", samples)
+		}
+	}
+	op := DefaultOptions
+	op.Location = AfterLinker
+	if _, err := NewDemuxer(testSamples, op); err == nil {
+		t.Errorf("Expected an error for no linker")
+	}
+}
+
+// buffers collects the outputs of Demux in memory
+type buffers map[string]*bytes.Buffer
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func (b buffers) open(name string) (io.WriteCloser, error) {
+	b[name] = &bytes.Buffer{}
+	return nopCloser{b[name]}, nil
+}
+
+func TestDemux(t *testing.T) {
+	input := "@r1\nAAAAAAGTGT\n+\nABCDEFGHIJ\n@r2\nCCCCCCGT\n+\nIIIIIIII\n@r3\nAAAAACGT\n+\nIIIIIIII\n@r4\nGGGG\n+\nIIII\n@r5\nAAAAAATT\n+\nIIIIIIII\n"
+	op := DefaultOptions
+	op.Trim = true
+	d, err := NewDemuxer(testSamples, op)
+	if err != nil {
+		t.Fatalf("NewDemuxer returned an error: %v", err)
+	}
+	outputs := buffers{}
+	stats, err := Demux(seqio.NewFastqReader(strings.NewReader(input)), d, outputs.open)
+	if err != nil {
+		t.Fatalf("Demux returned an error: %v", err)
+	}
+	expected := map[string]string{
+		"s1":           "@r1\nGTGT\n+\nGHIJ\n@r5\nTT\n+\nII\n",
+		"s2":           "@r2\nGT\n+\nII\n",
+		AmbiguousName:  "@r3\nAAAAACGT\n+\nIIIIIIII\n",
+		UnassignedName: "@r4\nGGGG\n+\nIIII\n",
+	}
+	if len(outputs) != len(expected) {
+		t.Errorf("Bad outputs: %v, expected %v", outputs, expected)
+	}
+	for name, out := range expected {
+		if outputs[name] == nil || outputs[name].String() != out {
+			t.Errorf("Bad output for %s: %q, expected %q", name, outputs[name], out)
+		}
+	}
+
+	var report bytes.Buffer
+	if err := stats.Write(&report); err != nil {
+		t.Fatalf("Write returned an error: %v", err)
+	}
+	expectedReport := "sample\tbarcode\treads\tpercent\ns1\tAAAAAA\t2\t40.00\ns2\tCCCCCC\t1\t20.00\ns3\tAAAACC\t0\t0.00\n" +
+		"ambiguous\t-\t1\t20.00\nunassigned\t-\t1\t20.00\n"
+	if report.String() != expectedReport {
+		t.Errorf("Bad stats:\n%q\nexpected\n%q", report.String(), expectedReport)
+	}
+}
@@ -7,13 +7,150 @@ type Match struct {
 	Dist  int
 }
 
+// EditOp is a single step of an Alignment
+type EditOp byte
+
+const (
+	// OpMatch aligns a pattern rune with a matching text rune
+	OpMatch EditOp = '='
+	// OpSub aligns a pattern rune with a text rune it does not match
+	OpSub EditOp = 'X'
+	// OpIns is a text rune that is not in the pattern
+	OpIns EditOp = 'I'
+	// OpDel is a pattern rune that is not in the text
+	OpDel EditOp = 'D'
+	// OpTrans aligns two adjacent pattern runes with the same two text runes
+	// swapped, it is only used when Options.TransCost is set
+	OpTrans EditOp = 'T'
+)
+
+// An Alignment is a Match along with the edits, from Start to End, that
+// transform the aligned part of the pattern into the matched text
+type Alignment struct {
+	Match
+	// PatternStart and PatternEnd are the part of the pattern that was aligned,
+	// the whole pattern unless the search allows part of it to go unaligned
+	PatternStart int
+	PatternEnd   int
+	Ops          []EditOp
+}
+
+// TracebackPolicy decides which move wins a tie during traceback, and so where
+// gaps are placed when several placements cost the same
+type TracebackPolicy int
+
+const (
+	// PreferDiagonal takes a match or substitution over a gap, and a deletion
+	// over an insertion
+	PreferDiagonal TracebackPolicy = iota
+	// LeftAlignGaps shifts insertions and deletions as far left as they will go,
+	// like left-normalized indels
+	LeftAlignGaps
+	// RightAlignGaps shifts insertions and deletions as far right as they will go
+	RightAlignGaps
+	// PreferLongestMatch takes an insertion over a diagonal over a deletion, which
+	// favours the earliest Start for a given End
+	PreferLongestMatch
+)
+
+// EndGaps says which ends of the pattern and the text an alignment may leave
+// unaligned for free
+type EndGaps uint8
+
+const (
+	// FreePatternPrefix lets the start of the pattern hang off the start of the text
+	FreePatternPrefix EndGaps = 1 << iota
+	// FreePatternSuffix lets the end of the pattern hang off the end of the text
+	FreePatternSuffix
+	// FreeTextPrefix lets the alignment start anywhere in the text
+	FreeTextPrefix
+	// FreeTextSuffix lets the alignment end anywhere in the text
+	FreeTextSuffix
+
+	// SemiGlobal finds the whole pattern anywhere in the text, what ApproxFind does
+	SemiGlobal = FreeTextPrefix | FreeTextSuffix
+	// Overlap also lets either end of the pattern hang off the text, for instance
+	// to find an adapter that has only partly been read at the end of a read
+	Overlap = FreePatternPrefix | FreePatternSuffix | FreeTextPrefix | FreeTextSuffix
+)
+
 type MatchFunction func(rune, rune) bool
 
+// PositionWeight holds the costs of editing one position of the pattern, used in
+// place of the costs in Options
+type PositionWeight struct {
+	// Sub is the cost of substituting this pattern rune
+	Sub int
+	// Del is the cost of deleting this pattern rune
+	Del int
+	// Ins is the cost of each text rune inserted just after this pattern rune
+	Ins int
+	// MustMatch forbids substituting, deleting, or transposing this pattern rune
+	MustMatch bool
+}
+
 type Options struct {
 	InsCost int
 	DelCost int
 	SubCost int
-	Matches MatchFunction
+	// TransCost is the cost of swapping two adjacent runes, as in "teh" for "the".
+	// Zero turns transpositions off.
+	TransCost int
+	Matches   MatchFunction
+	// MaxCoOptimal caps how many equally good alignments are traced back for each
+	// end column. Zero or one keeps only the first, preferring diagonal moves.
+	// LeftAlignGaps and RightAlignGaps can return fewer, since only a bounded
+	// number of paths are walked to find alignments that differ once shifted.
+	MaxCoOptimal int
+	// Traceback decides which move wins a tie during traceback
+	Traceback TracebackPolicy
+	// EndGaps says which ends may be left unaligned for free while searching. The
+	// zero value means SemiGlobal, GlobalAlign and Distance charge for every end.
+	EndGaps EndGaps
+	// Weights, if set, has one entry per pattern rune giving the costs of editing
+	// that position, for patterns where some positions matter more than others.
+	// UniformWeights builds a starting point from the costs above.
+	Weights []PositionWeight
+	// Normalize, if set, is applied to the pattern and the text before they are
+	// compared by the Levenshtein searches, Distance, and GlobalAlign. Matches
+	// are still reported against the original text, while Weights,
+	// PatternStart, PatternEnd, and Ops refer to the normalized runes.
+	Normalize Normalizer
+	// Graphemes compares user-perceived characters, grapheme clusters like an
+	// emoji with a skin tone or a letter with combining accents, instead of
+	// runes. An edit then changes a whole cluster, and Start and End never split
+	// one. Weights, PatternStart, PatternEnd, and Ops count clusters.
+	Graphemes bool
+	// MaxErrorRate, if above 0, also limits the cost of an alignment to
+	// floor(MaxErrorRate * n), n being the number of pattern runes it aligns,
+	// deleted ones included. A pattern that only partly overlaps the end of the
+	// text is then judged by the part that overlaps. maxE still applies, pass
+	// the pattern length to rely on the rate alone. Distance and GlobalAlign
+	// ignore it.
+	MaxErrorRate float64
+	// textSub scales the substitution cost at each text position, in parts of
+	// qualScale, for ApproxLevenQual
+	textSub []int
+	// costScale is what the costs have been multiplied by, for ApproxLevenQual.
+	// Zero means 1.
+	costScale int
+}
+
+// endGaps returns the end gaps to search with, SemiGlobal unless they were set
+func (op Options) endGaps() EndGaps {
+	if op.EndGaps == 0 {
+		return SemiGlobal
+	}
+	return op.EndGaps
+}
+
+// ScoreOptions are the scores used by LocalAlign. A match adds MatchScore, while
+// a mismatch or a gap subtracts its penalty, so all of them are positive numbers.
+type ScoreOptions struct {
+	MatchScore      int
+	MismatchPenalty int
+	GapPenalty      int
+	Matches         MatchFunction
 }
 
 // DefaultOptions is the default options: insertion cost is 1, deletion cost is
@@ -26,3 +163,14 @@ var DefaultOptions Options = Options{
 		return sourceCharacter == targetCharacter
 	},
 }
+
+// DefaultScoreOptions are the default scores for LocalAlign: a match scores 3, a
+// mismatch -3, a gap -2, and two runes match iff they are the same.
+var DefaultScoreOptions ScoreOptions = ScoreOptions{
+	MatchScore:      3,
+	MismatchPenalty: 3,
+	GapPenalty:      2,
+	Matches: func(sourceCharacter rune, targetCharacter rune) bool {
+		return sourceCharacter == targetCharacter
+	},
+}
@@ -0,0 +1,78 @@
+// Package normalize has Unicode normalizations for approx.Options.Normalize,
+// kept out of the approx package so that it only needs the standard library.
+package normalize
+
+import (
+	"unicode"
+
+	"github.com/sstadick/fuzzyfind/approx"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NFC composes runes, so "e" followed by a combining acute accent is "é"
+func NFC(s string, emit func(segment string, size int)) {
+	segments(norm.NFC, s, emit)
+}
+
+// NFKC composes runes and replaces compatibility characters, so the "ﬁ"
+// ligature is "fi" and a full width "Ａ" is "A"
+func NFKC(s string, emit func(segment string, size int)) {
+	segments(norm.NFKC, s, emit)
+}
+
+// StripDiacritics is NFKC with combining marks removed, so "é" is "e"
+func StripDiacritics(s string, emit func(segment string, size int)) {
+	segments(norm.NFKC, s, func(segment string, size int) {
+		emit(stripMarks(segment), size)
+	})
+}
+
+// segments emits s in form one normalization segment at a time
+func segments(form norm.Form, s string, emit func(segment string, size int)) {
+	var it norm.Iter
+	it.InitString(form, s)
+	for !it.Done() {
+		from := it.Pos()
+		segment := string(it.Next())
+		emit(segment, it.Pos()-from)
+	}
+}
+
+// stripMarks removes the combining marks from a composed segment
+func stripMarks(segment string) string {
+	stripped := []rune{}
+	for _, r := range norm.NFD.String(segment) {
+		if !unicode.Is(unicode.Mn, r) {
+			stripped = append(stripped, r)
+		}
+	}
+	return norm.NFC.String(string(stripped))
+}
+
+// NFCOptions are the approx.DefaultOptions with the pattern and the text in NFC
+var NFCOptions approx.Options = approx.Options{
+	InsCost:   1,
+	DelCost:   1,
+	SubCost:   1,
+	Matches:   approx.DefaultOptions.Matches,
+	Normalize: NFC,
+}
+
+// NFKCOptions are the approx.DefaultOptions with the pattern and the text in NFKC
+var NFKCOptions approx.Options = approx.Options{
+	InsCost:   1,
+	DelCost:   1,
+	SubCost:   1,
+	Matches:   approx.DefaultOptions.Matches,
+	Normalize: NFKC,
+}
+
+// FoldedOptions ignore case, compatibility differences, and diacritics, so
+// "Ｃafé" matches "CAFE"
+var FoldedOptions approx.Options = approx.Options{
+	InsCost:   1,
+	DelCost:   1,
+	SubCost:   1,
+	Matches:   approx.CaseFold(approx.DefaultOptions.Matches),
+	Normalize: StripDiacritics,
+}
@@ -0,0 +1,49 @@
+package normalize
+
+import (
+	"testing"
+
+	"github.com/sstadick/fuzzyfind/approx"
+)
+
+func TestNormalize(t *testing.T) {
+	decomposed := "café olé"
+	cases := []struct {
+		Pattern     string
+		Text        string
+		Description string
+		Options     approx.Options
+		Expected    []approx.Match
+	}{
+		{"café", decomposed, "NFC offsets in the original text", NFCOptions,
+			[]approx.Match{{Start: 0, End: 5, Dist: 0}}},
+		{"fine", "a ﬁne day", "NFKC ligature", NFKCOptions,
+			[]approx.Match{{Start: 2, End: 5, Dist: 0}}},
+		{"OLE", decomposed, "Folded diacritics and case", FoldedOptions,
+			[]approx.Match{{Start: 6, End: 10, Dist: 0}}},
+	}
+	for _, c := range cases {
+		matches, err := approx.ApproxFind(c.Pattern, c.Text, 0, c.Options)
+		if err != nil {
+			t.Errorf("ApproxFind returned an error for %s: %v", c.Description, err)
+			continue
+		}
+		if len(matches) != len(c.Expected) {
+			t.Errorf("Bad matches for %s: found %v, expected %v", c.Description, matches, c.Expected)
+			continue
+		}
+		for i := range matches {
+			if matches[i] != c.Expected[i] {
+				t.Errorf("Bad matches for %s: found %v, expected %v", c.Description, matches, c.Expected)
+				break
+			}
+		}
+	}
+
+	if d := approx.Distance("Crème Brûlée", "creme brulee", FoldedOptions); d != 0 {
+		t.Errorf("Bad folded distance: %d, expected 0", d)
+	}
+	if d := approx.Distance("Crème Brûlée", "creme brulee", approx.DefaultOptions); d != 5 {
+		t.Errorf("Bad distance: %d, expected 5", d)
+	}
+}
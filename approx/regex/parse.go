@@ -0,0 +1,303 @@
+package regex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// nodeKind is the kind of a node in a parsed expression
+type nodeKind int
+
+const (
+	nLit nodeKind = iota
+	nAny
+	nClass
+	nCat
+	nAlt
+	nRepeat
+	nGroup
+	nLimit
+	nBol
+	nEol
+	nEmpty
+)
+
+// maxRepeat caps the counts in {n,m} since repeats are compiled as copies
+const maxRepeat = 1000
+
+// runeRange is an inclusive range of runes in a character class
+type runeRange struct {
+	lo, hi rune
+}
+
+// node is a parsed regular expression
+type node struct {
+	kind    nodeKind
+	r       rune        // nLit
+	ranges  []runeRange // nClass
+	negate  bool        // nClass
+	subs    []*node     // nCat, nAlt, and the single child of the rest
+	min     int         // nRepeat
+	max     int         // nRepeat, -1 for no limit
+	capture int         // nGroup, 0 for a non-capturing group
+	limit   Limit       // nLimit
+}
+
+// parser is a recursive descent parser over the runes of an expression
+type parser struct {
+	expr   []rune
+	pos    int
+	ngroup int
+}
+
+func (p *parser) more() bool {
+	return p.pos < len(p.expr)
+}
+
+func (p *parser) peek() rune {
+	return p.expr[p.pos]
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("regex: %s at %d", fmt.Sprintf(format, args...), p.pos)
+}
+
+// parse parses a whole expression
+func parse(expr string) (*node, int, error) {
+	p := &parser{expr: []rune(expr)}
+	n, err := p.alternation()
+	if err != nil {
+		return nil, 0, err
+	}
+	if p.more() {
+		return nil, 0, p.errorf("unexpected %q", p.peek())
+	}
+	return n, p.ngroup, nil
+}
+
+// alternation := concatenation ('|' concatenation)*
+func (p *parser) alternation() (*node, error) {
+	n, err := p.concatenation()
+	if err != nil {
+		return nil, err
+	}
+	alt := &node{kind: nAlt, subs: []*node{n}}
+	for p.more() && p.peek() == '|' {
+		p.pos++
+		n, err := p.concatenation()
+		if err != nil {
+			return nil, err
+		}
+		alt.subs = append(alt.subs, n)
+	}
+	if len(alt.subs) == 1 {
+		return alt.subs[0], nil
+	}
+	return alt, nil
+}
+
+// concatenation := repetition*
+func (p *parser) concatenation() (*node, error) {
+	cat := &node{kind: nCat}
+	for p.more() && p.peek() != '|' && p.peek() != ')' {
+		n, err := p.repetition()
+		if err != nil {
+			return nil, err
+		}
+		cat.subs = append(cat.subs, n)
+	}
+	switch len(cat.subs) {
+	case 0:
+		return &node{kind: nEmpty}, nil
+	case 1:
+		return cat.subs[0], nil
+	}
+	return cat, nil
+}
+
+// repetition := atom ('*' | '+' | '?' | '{' bounds or limits '}')*
+func (p *parser) repetition() (*node, error) {
+	n, err := p.atom()
+	if err != nil {
+		return nil, err
+	}
+	for p.more() {
+		switch p.peek() {
+		case '*':
+			n = &node{kind: nRepeat, subs: []*node{n}, min: 0, max: -1}
+		case '+':
+			n = &node{kind: nRepeat, subs: []*node{n}, min: 1, max: -1}
+		case '?':
+			n = &node{kind: nRepeat, subs: []*node{n}, min: 0, max: 1}
+		case '{':
+			n, err = p.braces(n)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return n, nil
+		}
+		p.pos++
+	}
+	return n, nil
+}
+
+// braces parses either a count like {2}, {2,} or {2,5}, or TRE style cost limits
+// like {~2} or {+1 -1 #2}, leaving p.pos on the closing brace
+func (p *parser) braces(n *node) (*node, error) {
+	start := p.pos
+	end := p.pos
+	for end < len(p.expr) && p.expr[end] != '}' {
+		end++
+	}
+	if end == len(p.expr) {
+		return nil, p.errorf("unclosed '{'")
+	}
+	body := strings.TrimSpace(string(p.expr[start+1 : end]))
+	p.pos = end
+	if body == "" {
+		return nil, p.errorf("empty '{}'")
+	}
+	if strings.ContainsAny(body[:1], "~+-#") {
+		limit, err := parseLimit(body)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		return &node{kind: nLimit, subs: []*node{n}, limit: limit}, nil
+	}
+
+	lo, hi := body, body
+	if i := strings.IndexByte(body, ','); i >= 0 {
+		lo, hi = body[:i], body[i+1:]
+	}
+	min, err := strconv.Atoi(strings.TrimSpace(lo))
+	if err != nil {
+		return nil, p.errorf("bad repeat count %q", body)
+	}
+	max := -1
+	if hi = strings.TrimSpace(hi); hi != "" {
+		if max, err = strconv.Atoi(hi); err != nil {
+			return nil, p.errorf("bad repeat count %q", body)
+		}
+	}
+	if min < 0 || (max >= 0 && max < min) || min > maxRepeat || max > maxRepeat {
+		return nil, p.errorf("bad repeat count %q", body)
+	}
+	return &node{kind: nRepeat, subs: []*node{n}, min: min, max: max}, nil
+}
+
+// parseLimit parses TRE style cost limits, "~" for the total cost, "+" for
+// insertions, "-" for deletions, and "#" for substitutions, each followed by an
+// optional count (1 if left out) and separated by spaces or commas
+func parseLimit(body string) (Limit, error) {
+	limit := NoLimit
+	fields := strings.FieldsFunc(body, func(r rune) bool { return r == ' ' || r == ',' })
+	for _, f := range fields {
+		count := 1
+		if len(f) > 1 {
+			var err error
+			if count, err = strconv.Atoi(f[1:]); err != nil || count < 0 {
+				return limit, fmt.Errorf("bad cost limit %q", f)
+			}
+		}
+		switch f[0] {
+		case '~':
+			limit.Cost = count
+		case '+':
+			limit.Ins = count
+		case '-':
+			limit.Del = count
+		case '#':
+			limit.Sub = count
+		default:
+			return limit, fmt.Errorf("bad cost limit %q", f)
+		}
+	}
+	return limit, nil
+}
+
+// atom := literal | '.' | '^' | '$' | class | '(' alternation ')' | '\' rune
+func (p *parser) atom() (*node, error) {
+	r := p.peek()
+	switch r {
+	case '*', '+', '?', '{':
+		return nil, p.errorf("%q has nothing to repeat", r)
+	case '.':
+		p.pos++
+		return &node{kind: nAny}, nil
+	case '^':
+		p.pos++
+		return &node{kind: nBol}, nil
+	case '$':
+		p.pos++
+		return &node{kind: nEol}, nil
+	case '[':
+		return p.class()
+	case '(':
+		return p.group()
+	case '\\':
+		p.pos++
+		if !p.more() {
+			return nil, p.errorf("trailing '\\'")
+		}
+		r = p.peek()
+	}
+	p.pos++
+	return &node{kind: nLit, r: r}, nil
+}
+
+// group := '(' ('?:')? alternation ')'
+func (p *parser) group() (*node, error) {
+	p.pos++
+	capture := 0
+	if p.pos+1 < len(p.expr) && p.expr[p.pos] == '?' && p.expr[p.pos+1] == ':' {
+		p.pos += 2
+	} else {
+		p.ngroup++
+		capture = p.ngroup
+	}
+	n, err := p.alternation()
+	if err != nil {
+		return nil, err
+	}
+	if !p.more() || p.peek() != ')' {
+		return nil, p.errorf("missing ')'")
+	}
+	p.pos++
+	return &node{kind: nGroup, subs: []*node{n}, capture: capture}, nil
+}
+
+// class := '[' '^'? (rune | rune '-' rune)+ ']'
+func (p *parser) class() (*node, error) {
+	start := p.pos
+	p.pos++
+	n := &node{kind: nClass}
+	if p.more() && p.peek() == '^' {
+		n.negate = true
+		p.pos++
+	}
+	for p.more() && (p.peek() != ']' || len(n.ranges) == 0) {
+		lo := p.peek()
+		if lo == '\\' && p.pos+1 < len(p.expr) {
+			p.pos++
+			lo = p.peek()
+		}
+		hi := lo
+		if p.pos+2 < len(p.expr) && p.expr[p.pos+1] == '-' && p.expr[p.pos+2] != ']' {
+			hi = p.expr[p.pos+2]
+			p.pos += 2
+			if hi < lo {
+				return nil, p.errorf("bad range %c-%c", lo, hi)
+			}
+		}
+		n.ranges = append(n.ranges, runeRange{lo, hi})
+		p.pos++
+	}
+	if !p.more() {
+		p.pos = start
+		return nil, p.errorf("unclosed '['")
+	}
+	p.pos++
+	return n, nil
+}
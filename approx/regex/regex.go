@@ -0,0 +1,524 @@
+// Package regex is an approximate regular expression engine in the style of TRE.
+// An expression is matched against a text within a cost budget, using the
+// insertion, deletion, and substitution costs of approx.Options, and parts of the
+// expression can carry their own limits, like (GATTACA){~1} to allow at most one
+// error in that group.
+package regex
+
+import (
+	"github.com/sstadick/fuzzyfind/approx"
+)
+
+// Limit caps the errors allowed within a subexpression. Cost caps the total cost of
+// its errors, and Ins, Del, and Sub cap the number of each kind. A negative value
+// means no limit.
+type Limit struct {
+	Cost int
+	Ins  int
+	Del  int
+	Sub  int
+}
+
+// NoLimit is a Limit that allows anything
+var NoLimit = Limit{Cost: -1, Ins: -1, Del: -1, Sub: -1}
+
+// Result is a match of a Regexp
+type Result struct {
+	approx.Match
+	// Groups holds the start and end of each capture group, with Groups[0] being
+	// the whole match. Groups that took no part in the match are {-1, -1}.
+	Groups [][2]int
+}
+
+// Regexp is a compiled approximate regular expression. It is safe to use from
+// several goroutines at once.
+type Regexp struct {
+	expr   string
+	prog   []inst
+	ngroup int
+	limits []Limit
+}
+
+// opcode is the operation of an instruction
+type opcode int
+
+const (
+	opRune opcode = iota
+	opAny
+	opClass
+	opSplit
+	opJmp
+	opSave
+	opLimit
+	opBol
+	opEol
+	opMatch
+)
+
+// inst is a single instruction of a compiled Regexp
+type inst struct {
+	op     opcode
+	r      rune        // opRune
+	ranges []runeRange // opClass
+	negate bool        // opClass
+	x, y   int         // the targets of opSplit, x is preferred, and of opJmp
+	n      int         // the slot of opSave, the limit region of opLimit
+	// regions are the limits enclosing this instruction, charged for any
+	// errors made on it
+	regions []int
+}
+
+// Compile parses a regular expression. The syntax is a subset of Go's regexp:
+// literals, '.', character classes, '^' and '$', groups with '(' and ')' (or "(?:"
+// for a group that doesn't capture), alternation with '|', and repetition with
+// '*', '+', '?', and {n,m}. After an atom or group, TRE style limits in braces
+// restrict the errors within it: {~2} for a total cost of 2, {+1} for one
+// insertion, {-1} for one deletion, {#1} for one substitution, or a combination
+// like {~2 #1}. Literals and the runes of classes are compared with
+// Options.Matches.
+func Compile(expr string) (*Regexp, error) {
+	tree, ngroup, err := parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	c := &compiler{}
+	c.compile(tree)
+	c.emit(inst{op: opMatch})
+	return &Regexp{expr: expr, prog: c.prog, ngroup: ngroup, limits: c.limits}, nil
+}
+
+// MustCompile is like Compile but panics if the expression can't be parsed
+func MustCompile(expr string) *Regexp {
+	re, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return re
+}
+
+// String returns the source of the expression
+func (re *Regexp) String() string {
+	return re.expr
+}
+
+// NumGroups returns the number of capture groups in the expression
+func (re *Regexp) NumGroups() int {
+	return re.ngroup
+}
+
+// compiler turns a parsed expression into a program
+type compiler struct {
+	prog    []inst
+	regions []int
+	limits  []Limit
+}
+
+func (c *compiler) emit(i inst) int {
+	i.regions = append([]int{}, c.regions...)
+	c.prog = append(c.prog, i)
+	return len(c.prog) - 1
+}
+
+func (c *compiler) compile(n *node) {
+	switch n.kind {
+	case nLit:
+		c.emit(inst{op: opRune, r: n.r})
+	case nAny:
+		c.emit(inst{op: opAny})
+	case nClass:
+		c.emit(inst{op: opClass, ranges: n.ranges, negate: n.negate})
+	case nBol:
+		c.emit(inst{op: opBol})
+	case nEol:
+		c.emit(inst{op: opEol})
+	case nEmpty:
+	case nCat:
+		for _, sub := range n.subs {
+			c.compile(sub)
+		}
+	case nAlt:
+		// split L1, next; L1: sub; jmp end; next: ...
+		jumps := []int{}
+		for k, sub := range n.subs {
+			if k == len(n.subs)-1 {
+				c.compile(sub)
+				break
+			}
+			split := c.emit(inst{op: opSplit})
+			c.prog[split].x = len(c.prog)
+			c.compile(sub)
+			jumps = append(jumps, c.emit(inst{op: opJmp}))
+			c.prog[split].y = len(c.prog)
+		}
+		for _, j := range jumps {
+			c.prog[j].x = len(c.prog)
+		}
+	case nGroup:
+		if n.capture == 0 {
+			c.compile(n.subs[0])
+			break
+		}
+		c.emit(inst{op: opSave, n: 2 * n.capture})
+		c.compile(n.subs[0])
+		c.emit(inst{op: opSave, n: 2*n.capture + 1})
+	case nLimit:
+		k := len(c.limits)
+		c.limits = append(c.limits, n.limit)
+		c.emit(inst{op: opLimit, n: k})
+		c.regions = append(c.regions, k)
+		c.compile(n.subs[0])
+		c.regions = c.regions[:len(c.regions)-1]
+	case nRepeat:
+		c.repeat(n)
+	}
+}
+
+// repeat compiles the minimum number of copies, then either a loop or the optional
+// copies up to the maximum
+func (c *compiler) repeat(n *node) {
+	sub := n.subs[0]
+	for k := 0; k < n.min; k++ {
+		c.compile(sub)
+	}
+	if n.max < 0 {
+		// L: split body, end; body: sub; jmp L
+		split := c.emit(inst{op: opSplit})
+		c.prog[split].x = len(c.prog)
+		c.compile(sub)
+		jmp := c.emit(inst{op: opJmp})
+		c.prog[jmp].x = split
+		c.prog[split].y = len(c.prog)
+		return
+	}
+	splits := []int{}
+	for k := n.min; k < n.max; k++ {
+		split := c.emit(inst{op: opSplit})
+		c.prog[split].x = len(c.prog)
+		splits = append(splits, split)
+		c.compile(sub)
+	}
+	for _, s := range splits {
+		c.prog[s].y = len(c.prog)
+	}
+}
+
+// usage is the errors a thread has made within one limit region
+type usage struct {
+	cost, ins, del, sub int
+}
+
+// thread is a way of matching the expression so far
+type thread struct {
+	start int
+	cost  int
+	caps  []int
+	used  []usage
+}
+
+// edit is the kind of error being charged to a thread
+type edit int
+
+const (
+	editIns edit = iota
+	editDel
+	editSub
+)
+
+// queue holds the threads at each instruction that no other thread there beats,
+// with the instructions in the order they were first added
+type queue struct {
+	threads [][]*thread
+	order   []int
+}
+
+func newQueue(n int) *queue {
+	return &queue{threads: make([][]*thread, n)}
+}
+
+func (q *queue) clear() {
+	for _, pc := range q.order {
+		q.threads[pc] = q.threads[pc][:0]
+	}
+	q.order = q.order[:0]
+}
+
+// machine runs a program over one text
+type machine struct {
+	re   *Regexp
+	text []rune
+	maxE int
+	op   approx.Options
+}
+
+// charge returns a copy of t that has paid for an edit made at instruction pc, or
+// false if that would go over the budget or a limit
+func (m *machine) charge(t *thread, pc int, e edit) (*thread, bool) {
+	var cost int
+	switch e {
+	case editIns:
+		cost = m.op.InsCost
+	case editDel:
+		cost = m.op.DelCost
+	case editSub:
+		cost = m.op.SubCost
+	}
+	if t.cost+cost > m.maxE {
+		return nil, false
+	}
+	nt := &thread{start: t.start, cost: t.cost + cost, caps: t.caps, used: t.used}
+	regions := m.re.prog[pc].regions
+	if len(regions) == 0 {
+		return nt, true
+	}
+	nt.used = append([]usage{}, t.used...)
+	for _, k := range regions {
+		u := &nt.used[k]
+		u.cost += cost
+		switch e {
+		case editIns:
+			u.ins++
+		case editDel:
+			u.del++
+		case editSub:
+			u.sub++
+		}
+		if !m.re.limits[k].allows(*u) {
+			return nil, false
+		}
+	}
+	return nt, true
+}
+
+// allows reports whether the errors in u are within the limit
+func (limit Limit) allows(u usage) bool {
+	return (limit.Cost < 0 || u.cost <= limit.Cost) &&
+		(limit.Ins < 0 || u.ins <= limit.Ins) &&
+		(limit.Del < 0 || u.del <= limit.Del) &&
+		(limit.Sub < 0 || u.sub <= limit.Sub)
+}
+
+// accepts reports whether the rune instruction in accepts r. A class accepts r
+// when op.Matches accepts it for any rune of the class, so classes fold case
+// like literals do.
+func (m *machine) accepts(in *inst, r rune) bool {
+	switch in.op {
+	case opAny:
+		return true
+	case opRune:
+		return m.op.Matches(in.r, r)
+	}
+	found := false
+	for _, rr := range in.ranges {
+		if rr.lo <= r && r <= rr.hi {
+			found = true
+			break
+		}
+	}
+	// Only try the runes of the class one at a time when r isn't one of them
+	for _, rr := range in.ranges {
+		for c := rr.lo; !found && c <= rr.hi; c++ {
+			found = m.op.Matches(c, r)
+		}
+	}
+	return found != in.negate
+}
+
+// beats reports whether thread a can do anything thread b can from instruction
+// pc: it costs no more, and has used no more of any limit enclosing pc. Limits
+// that don't enclose pc are reset before they are entered again.
+func (m *machine) beats(a *thread, b *thread, pc int) bool {
+	if a.cost > b.cost {
+		return false
+	}
+	for _, k := range m.re.prog[pc].regions {
+		ua, ub := a.used[k], b.used[k]
+		if ua.cost > ub.cost || ua.ins > ub.ins || ua.del > ub.del || ua.sub > ub.sub {
+			return false
+		}
+	}
+	return true
+}
+
+// add puts t on q at pc, with pos runes of the text read, and follows every move
+// that doesn't read a rune, including deleting a rune of the expression. A thread
+// is dropped if one already at pc beats it, so earlier threads win ties, and
+// replaces the threads it beats. Outside limits that keeps only the cheapest.
+func (m *machine) add(q *queue, pc int, t *thread, pos int) {
+	for _, cur := range q.threads[pc] {
+		if m.beats(cur, t, pc) {
+			return
+		}
+	}
+	kept := q.threads[pc][:0]
+	for _, cur := range q.threads[pc] {
+		if !m.beats(t, cur, pc) {
+			kept = append(kept, cur)
+		}
+	}
+	if len(q.threads[pc]) == 0 {
+		q.order = append(q.order, pc)
+	}
+	q.threads[pc] = append(kept, t)
+	in := &m.re.prog[pc]
+	switch in.op {
+	case opJmp:
+		m.add(q, in.x, t, pos)
+	case opSplit:
+		m.add(q, in.x, t, pos)
+		m.add(q, in.y, t, pos)
+	case opSave:
+		nt := *t
+		nt.caps = append([]int{}, t.caps...)
+		nt.caps[in.n] = pos
+		m.add(q, pc+1, &nt, pos)
+	case opLimit:
+		nt := *t
+		nt.used = append([]usage{}, t.used...)
+		nt.used[in.n] = usage{}
+		m.add(q, pc+1, &nt, pos)
+	case opBol:
+		if pos == 0 {
+			m.add(q, pc+1, t, pos)
+		}
+	case opEol:
+		if pos == len(m.text) {
+			m.add(q, pc+1, t, pos)
+		}
+	case opRune, opAny, opClass:
+		if nt, ok := m.charge(t, pc, editDel); ok {
+			m.add(q, pc+1, nt, pos)
+		}
+	}
+}
+
+// run finds the best match ending at each position of the text, where the best is
+// the cheapest, then the one that starts first
+func (m *machine) run() []Result {
+	n := len(m.re.prog)
+	clist, nlist := newQueue(n), newQueue(n)
+	fresh := func(pos int) *thread {
+		caps := make([]int, 2*(m.re.ngroup+1))
+		for i := range caps {
+			caps[i] = -1
+		}
+		return &thread{start: pos, caps: caps, used: make([]usage, len(m.re.limits))}
+	}
+
+	results := []Result{}
+	m.add(clist, 0, fresh(0), 0)
+	for pos := 0; ; pos++ {
+		for _, pc := range clist.order {
+			if m.re.prog[pc].op == opMatch {
+				// No limit encloses the end, so only the cheapest is left
+				results = append(results, m.result(clist.threads[pc][0], pos))
+			}
+		}
+		if pos == len(m.text) {
+			break
+		}
+		r := m.text[pos]
+		for _, pc := range clist.order {
+			for _, t := range clist.threads[pc] {
+				m.step(nlist, pc, t, r, pos)
+			}
+		}
+		// A match can start at every position
+		m.add(nlist, 0, fresh(pos+1), pos+1)
+		clist, nlist = nlist, clist
+		nlist.clear()
+	}
+	return results
+}
+
+// step moves thread t at pc past the rune r at pos, onto q
+func (m *machine) step(q *queue, pc int, t *thread, r rune, pos int) {
+	in := &m.re.prog[pc]
+	if in.op == opMatch {
+		// Runes inserted after the whole expression count as part of the match,
+		// as the last row of the Levenshtein matrix does
+		if nt, ok := m.charge(t, pc, editIns); ok {
+			m.add(q, pc, nt, pos+1)
+		}
+		return
+	}
+	if in.op != opRune && in.op != opAny && in.op != opClass {
+		return
+	}
+	if m.accepts(in, r) {
+		m.add(q, pc+1, t, pos+1)
+	} else if nt, ok := m.charge(t, pc, editSub); ok {
+		m.add(q, pc+1, nt, pos+1)
+	}
+	if nt, ok := m.charge(t, pc, editIns); ok {
+		m.add(q, pc, nt, pos+1)
+	}
+}
+
+// result builds the Result of a thread that matched at end
+func (m *machine) result(t *thread, end int) Result {
+	groups := make([][2]int, m.re.ngroup+1)
+	groups[0] = [2]int{t.start, end}
+	for g := 1; g <= m.re.ngroup; g++ {
+		groups[g] = [2]int{t.caps[2*g], t.caps[2*g+1]}
+		if groups[g][0] < 0 || groups[g][1] < 0 {
+			groups[g] = [2]int{-1, -1}
+		}
+	}
+	return Result{Match: approx.Match{Start: t.start, End: end, Dist: t.cost}, Groups: groups}
+}
+
+// FindEnds returns the best match ending at each position of text within a total
+// cost of maxE, like approx.ApproxFind does for plain patterns. Positions are rune
+// indexes into text.
+func (re *Regexp) FindEnds(text string, maxE int, op approx.Options) []Result {
+	m := &machine{re: re, text: []rune(text), maxE: maxE, op: op}
+	return m.run()
+}
+
+// Find returns the best match of the expression in text within a total cost of
+// maxE: the cheapest, then the leftmost, then the longest. The bool is false if
+// there is no match.
+func (re *Regexp) Find(text string, maxE int, op approx.Options) (Result, bool) {
+	results := re.FindEnds(text, maxE, op)
+	if len(results) == 0 {
+		return Result{}, false
+	}
+	best := results[0]
+	for _, r := range results[1:] {
+		if better(r.Match, best.Match) {
+			best = r
+		}
+	}
+	return best, true
+}
+
+// FindAll returns non-overlapping matches of the expression in text, taking the
+// best matches first as approx.NonOverlapping does, and ordered by where they
+// start.
+func (re *Regexp) FindAll(text string, maxE int, op approx.Options) []Result {
+	results := re.FindEnds(text, maxE, op)
+	byMatch := make(map[approx.Match]Result, len(results))
+	matches := make([]approx.Match, len(results))
+	for i, r := range results {
+		matches[i] = r.Match
+		byMatch[r.Match] = r
+	}
+	// ResolveOverlaps only fails for a policy it doesn't know
+	kept, _ := approx.ResolveOverlaps(matches, approx.NonOverlapping)
+	all := make([]Result, len(kept))
+	for i, k := range kept {
+		all[i] = byMatch[k]
+	}
+	return all
+}
+
+// better reports whether a is a better match than b
+func better(a, b approx.Match) bool {
+	if a.Dist != b.Dist {
+		return a.Dist < b.Dist
+	}
+	if a.Start != b.Start {
+		return a.Start < b.Start
+	}
+	return a.End-a.Start > b.End-b.Start
+}
@@ -0,0 +1,245 @@
+package regex
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/sstadick/fuzzyfind/approx"
+)
+
+func TestFind(t *testing.T) {
+	cases := []struct {
+		Expr        string
+		Text        string
+		MaxE        int
+		Description string
+		Found       bool
+		Expected    Result
+	}{
+		{"GATTACA", "xxGATTACAxx", 0, "Exact literal", true,
+			Result{approx.Match{Start: 2, End: 9, Dist: 0}, [][2]int{{2, 9}}}},
+		{"GATTACA", "xxGATCACAxx", 1, "Literal with a substitution", true,
+			Result{approx.Match{Start: 2, End: 9, Dist: 1}, [][2]int{{2, 9}}}},
+		{"GATTACA", "xxGATCACAxx", 0, "Literal over budget", false, Result{}},
+		{"AC(G|TT)A", "xxACTTAxx", 0, "Alternation", true,
+			Result{approx.Match{Start: 2, End: 7, Dist: 0}, [][2]int{{2, 7}, {4, 6}}}},
+		{"A(C+)G", "ACCCG", 0, "Repetition", true,
+			Result{approx.Match{Start: 0, End: 5, Dist: 0}, [][2]int{{0, 5}, {1, 4}}}},
+		{"AC{2,3}G", "ACCCCG", 1, "Bounded repetition with a deletion", true,
+			Result{approx.Match{Start: 0, End: 6, Dist: 1}, [][2]int{{0, 6}}}},
+		{"A(x)?C", "AC", 0, "Unused group", true,
+			Result{approx.Match{Start: 0, End: 2, Dist: 0}, [][2]int{{0, 2}, {-1, -1}}}},
+		{"^AC[GT]", "ACT", 0, "Anchor and class", true,
+			Result{approx.Match{Start: 0, End: 3, Dist: 0}, [][2]int{{0, 3}}}},
+		{"^ACG", "xACG", 0, "Anchor stops a later match", false, Result{}},
+		{"(?:AAAA){~1}(CCCC)", "AAGACCCC", 5, "Group limit allows one error", true,
+			Result{approx.Match{Start: 0, End: 8, Dist: 1}, [][2]int{{0, 8}, {4, 8}}}},
+		{"(?:AAAA){~0}CCCC", "AAGACCCCxAAAAGCCC", 1, "Group limit allows no errors", true,
+			Result{approx.Match{Start: 9, End: 17, Dist: 1}, [][2]int{{9, 17}}}},
+		{"(?:ACGT){#0}", "ACTT", 1, "Substitutions not allowed", true,
+			Result{approx.Match{Start: 0, End: 3, Dist: 1}, [][2]int{{0, 3}}}},
+	}
+	for _, c := range cases {
+		re, err := Compile(c.Expr)
+		if err != nil {
+			t.Errorf("Compile failed for %s: %v", c.Description, err)
+			continue
+		}
+		r, found := re.Find(c.Text, c.MaxE, approx.DefaultOptions)
+		if found != c.Found {
+			t.Errorf("Bad found for %s: got %v", c.Description, found)
+			continue
+		}
+		if !found {
+			continue
+		}
+		if r.Match != c.Expected.Match || len(r.Groups) != len(c.Expected.Groups) {
+			t.Errorf("Bad match for %s: found %v, expected %v", c.Description, r, c.Expected)
+			continue
+		}
+		for g := range r.Groups {
+			if r.Groups[g] != c.Expected.Groups[g] {
+				t.Errorf("Bad group %d for %s: found %v, expected %v", g, c.Description, r.Groups[g], c.Expected.Groups[g])
+			}
+		}
+	}
+}
+
+func TestFindCaseInsensitive(t *testing.T) {
+	// Classes fold case like literals
+	cases := []struct {
+		Expr     string
+		Text     string
+		Expected approx.Match
+	}{
+		{"a[ac]", "xAC", approx.Match{Start: 1, End: 3, Dist: 0}},
+		{"A[a-c]G", "xAbGx", approx.Match{Start: 1, End: 4, Dist: 0}},
+		{"A[^c]G", "xACGxATGx", approx.Match{Start: 5, End: 8, Dist: 0}},
+	}
+	for _, c := range cases {
+		r, found := MustCompile(c.Expr).Find(c.Text, 0, approx.CaseInsensitiveOptions)
+		if !found || r.Match != c.Expected {
+			t.Errorf("Bad case insensitive match of %s in %s: %v %v, expected %v", c.Expr, c.Text, r.Match, found, c.Expected)
+		}
+	}
+}
+
+func TestFindAll(t *testing.T) {
+	re := MustCompile("GAT+ACA")
+	results := re.FindAll("GATTACAxxxxGATACAxxxxGATTCCA", 1, approx.DefaultOptions)
+	expected := []approx.Match{{Start: 0, End: 7, Dist: 0}, {Start: 11, End: 17, Dist: 0}, {Start: 21, End: 28, Dist: 1}}
+	if len(results) != len(expected) {
+		t.Fatalf("Bad number of matches: found %v, expected %v", results, expected)
+	}
+	for i, r := range results {
+		if r.Match != expected[i] {
+			t.Errorf("Bad match %d: found %v, expected %v", i, r.Match, expected[i])
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, bad := range []string{"(AC", "AC)", "*A", "A{2", "A{3,1}", "A{~x}", "[AC", "A\\", "[C-A]"} {
+		if _, err := Compile(bad); err == nil {
+			t.Errorf("Expected an error compiling %q", bad)
+		}
+	}
+}
+
+// errors is the number of insertions, deletions, and substitutions of an alignment
+type errors [3]int
+
+// alignErrors returns every count of errors that aligns all of p to all of t.
+// Unless trailing is set, the alignment may not end by inserting a rune of t,
+// since the engine charges those runes to what follows.
+func alignErrors(p string, t string, trailing bool) map[errors]bool {
+	// sets[i][j] holds the errors aligning p[:i] to t[:j]
+	sets := make([][]map[errors]bool, len(p)+1)
+	for i := range sets {
+		sets[i] = make([]map[errors]bool, len(t)+1)
+		for j := range sets[i] {
+			sets[i][j] = map[errors]bool{}
+			if i == 0 && j == 0 {
+				sets[i][j][errors{}] = true
+			}
+			if i > 0 && j > 0 {
+				for e := range sets[i-1][j-1] {
+					if p[i-1] != t[j-1] {
+						e[2]++
+					}
+					sets[i][j][e] = true
+				}
+			}
+			if i > 0 {
+				for e := range sets[i-1][j] {
+					e[1]++
+					sets[i][j][e] = true
+				}
+			}
+			if j > 0 && (trailing || i == 0 || j < len(t) || i < len(p)) {
+				for e := range sets[i][j-1] {
+					e[0]++
+					sets[i][j][e] = true
+				}
+			}
+		}
+	}
+	return sets[len(p)][len(t)]
+}
+
+// cheapest returns the lowest total of the errors in set within limit, or -1
+func cheapest(set map[errors]bool, limit Limit) int {
+	best := -1
+	for e := range set {
+		cost := e[0] + e[1] + e[2]
+		if limit.allows(usage{cost: cost, ins: e[0], del: e[1], sub: e[2]}) && (best < 0 || cost < best) {
+			best = cost
+		}
+	}
+	return best
+}
+
+func TestFindEndsExhaustive(t *testing.T) {
+	// Expressions of the form before(limited){limit}after
+	exprs := []struct {
+		Before, Limited, After string
+		Limit                  string
+		Parsed                 Limit
+	}{
+		{"A", "CGT", "A", "~1", Limit{Cost: 1, Ins: -1, Del: -1, Sub: -1}},
+		{"AC", "GTA", "C", "#0", Limit{Cost: -1, Ins: -1, Del: -1, Sub: 0}},
+		{"", "ACG", "TT", "+0", Limit{Cost: -1, Ins: 0, Del: -1, Sub: -1}},
+		{"GA", "TTC", "", "-0", Limit{Cost: -1, Ins: -1, Del: 0, Sub: -1}},
+		{"C", "AGGT", "CA", "~2 #1", Limit{Cost: 2, Ins: -1, Del: -1, Sub: 1}},
+		{"T", "GCA", "G", "+1 -1 #0", Limit{Cost: -1, Ins: 1, Del: 1, Sub: 0}},
+	}
+	// cheapest alignment of a part of the expression to a part of the text
+	type part struct {
+		p, text  string
+		trailing bool
+		limit    Limit
+	}
+	memo := map[part]int{}
+	align := func(p string, text string, trailing bool, limit Limit) int {
+		key := part{p, text, trailing, limit}
+		if cost, ok := memo[key]; ok {
+			return cost
+		}
+		cost := cheapest(alignErrors(p, text, trailing), limit)
+		memo[key] = cost
+		return cost
+	}
+	r := rand.New(rand.NewSource(1))
+	for _, e := range exprs {
+		re := MustCompile(e.Before + "(?:" + e.Limited + "){" + e.Limit + "}" + e.After)
+		for iter := 0; iter < 2000; iter++ {
+			text := make([]byte, 1+r.Intn(8))
+			for i := range text {
+				text[i] = "ACGT"[r.Intn(4)]
+			}
+			maxE := r.Intn(4)
+			expected := map[int]int{}
+			for end := 0; end <= len(text); end++ {
+				best := -1
+				for start := 0; start <= end; start++ {
+					for a := start; a <= end; a++ {
+						before := align(e.Before, string(text[start:a]), false, NoLimit)
+						if before < 0 {
+							continue
+						}
+						for b := a; b <= end; b++ {
+							limited := align(e.Limited, string(text[a:b]), false, e.Parsed)
+							after := align(e.After, string(text[b:end]), true, NoLimit)
+							if limited < 0 || after < 0 {
+								continue
+							}
+							if cost := before + limited + after; cost <= maxE && (best < 0 || cost < best) {
+								best = cost
+							}
+						}
+					}
+				}
+				if best >= 0 {
+					expected[end] = best
+				}
+			}
+			found := map[int]int{}
+			for _, res := range re.FindEnds(string(text), maxE, approx.DefaultOptions) {
+				found[res.End] = res.Dist
+			}
+			if len(found) != len(expected) {
+				t.Fatalf("Bad ends for %s in %s within %d: found %v, expected %v", re, text, maxE, found, expected)
+			}
+			for end, dist := range expected {
+				if d, ok := found[end]; !ok || d != dist {
+					t.Fatalf("Bad ends for %s in %s within %d: found %v, expected %v", re, text, maxE, found, expected)
+				}
+			}
+		}
+	}
+
+	re := MustCompile("A(CGT){~1}A")
+	if res, found := re.Find("CAGCGCA", 2, approx.DefaultOptions); !found || res.Match != (approx.Match{Start: 2, End: 7, Dist: 2}) {
+		t.Errorf("Bad match with an error on each side of a limit: %v %v", res, found)
+	}
+}
@@ -0,0 +1,173 @@
+package seqio
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sstadick/fuzzyfind/approx"
+)
+
+// A HitWriter writes hits in some format. Call Flush when done.
+type HitWriter interface {
+	Write(h Hit) error
+	Flush() error
+}
+
+// MatchHits turns the matches of a query in a reference into Hits, for writing
+// matches that have no alignment. The hits are on the forward strand. SAMWriter
+// needs an alignment and can't write them.
+func MatchHits(queryName string, query string, ref string, matches []approx.Match) []Hit {
+	hits := make([]Hit, len(matches))
+	for i, m := range matches {
+		hits[i] = Hit{QueryName: queryName, Query: query, Ref: ref, Alignment: approx.Alignment{Match: m}}
+	}
+	return hits
+}
+
+// strand is the strand of a hit as '+' or '-'
+func (h Hit) strand() string {
+	if h.Reverse {
+		return "-"
+	}
+	return "+"
+}
+
+// BEDWriter writes hits as BED6: the reference, the 0-based start and end, the
+// query name, the Dist as the score, and the strand
+type BEDWriter struct {
+	w *bufio.Writer
+}
+
+// NewBEDWriter returns a BEDWriter writing to w
+func NewBEDWriter(w io.Writer) *BEDWriter {
+	return &BEDWriter{w: bufio.NewWriter(w)}
+}
+
+// Write writes a hit as a BED line
+func (b *BEDWriter) Write(h Hit) error {
+	_, err := fmt.Fprintf(b.w, "%s\t%d\t%d\t%s\t%d\t%s\n", h.Ref, h.Alignment.Start, h.Alignment.End, h.QueryName, h.Alignment.Dist, h.strand())
+	return err
+}
+
+// Flush writes any buffered lines
+func (b *BEDWriter) Flush() error {
+	return b.w.Flush()
+}
+
+// GFFWriter writes hits as GFF3 match features. Each feature gets an ID, the
+// query name as its Name, and a Gap attribute if the hit has edits.
+type GFFWriter struct {
+	w      *bufio.Writer
+	source string
+	n      int
+}
+
+// NewGFFWriter writes the GFF3 header and returns a GFFWriter with source in the
+// second column, "fuzzyfind" if it is empty
+func NewGFFWriter(w io.Writer, source string) (*GFFWriter, error) {
+	if source == "" {
+		source = "fuzzyfind"
+	}
+	g := &GFFWriter{w: bufio.NewWriter(w), source: source}
+	_, err := fmt.Fprintf(g.w, "##gff-version 3\n")
+	return g, err
+}
+
+// Write writes a hit as a GFF3 line, with 1-based inclusive coordinates
+func (g *GFFWriter) Write(h Hit) error {
+	g.n++
+	attrs := fmt.Sprintf("ID=match%d;Name=%s", g.n, gffEscape(h.QueryName))
+	if gap := gffGap(h.Alignment); gap != "" {
+		attrs += ";Gap=" + gap
+	}
+	_, err := fmt.Fprintf(g.w, "%s\t%s\tmatch\t%d\t%d\t%d\t%s\t.\t%s\n",
+		gffEscape(h.Ref), g.source, h.Alignment.Start+1, h.Alignment.End, h.Alignment.Dist, h.strand(), attrs)
+	return err
+}
+
+// Flush writes any buffered lines
+func (g *GFFWriter) Flush() error {
+	return g.w.Flush()
+}
+
+// gffEscape percent encodes the characters that GFF3 reserves
+func gffEscape(s string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < ' ' || strings.IndexByte("%;=&,", c) >= 0 {
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		} else {
+			escaped.WriteByte(c)
+		}
+	}
+	return escaped.String()
+}
+
+// gffGap turns the edits of an alignment into a GFF3 Gap attribute, like
+// "M8 D3 M6", which uses the same letters as a CIGAR
+func gffGap(a approx.Alignment) string {
+	if len(a.Ops) == 0 {
+		return ""
+	}
+	cigar := CIGAR(approx.Alignment{Ops: a.Ops}, 0)
+	gap := []string{}
+	for len(cigar) > 0 {
+		i := strings.IndexAny(cigar, "MIDS")
+		gap = append(gap, cigar[i:i+1]+cigar[:i])
+		cigar = cigar[i+1:]
+	}
+	return strings.Join(gap, " ")
+}
+
+// jsonHit is the JSON form of a Hit
+type jsonHit struct {
+	Pattern   string `json:"pattern"`
+	PatternID string `json:"pattern_id"`
+	Seq       string `json:"seq"`
+	Start     int    `json:"start"`
+	End       int    `json:"end"`
+	Dist      int    `json:"dist"`
+	Strand    string `json:"strand"`
+	CIGAR     string `json:"cigar,omitempty"`
+}
+
+// JSONWriter writes hits as newline-delimited JSON, one object per hit with the
+// pattern, its id (the query name), the sequence (the reference), the 0-based
+// start and end, dist, strand, and cigar if the hit has edits
+type JSONWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewJSONWriter returns a JSONWriter writing to w
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	return &JSONWriter{w: bw, enc: enc}
+}
+
+// Write writes a hit as a line of JSON
+func (j *JSONWriter) Write(h Hit) error {
+	jh := jsonHit{
+		Pattern:   h.Query,
+		PatternID: h.QueryName,
+		Seq:       h.Ref,
+		Start:     h.Alignment.Start,
+		End:       h.Alignment.End,
+		Dist:      h.Alignment.Dist,
+		Strand:    h.strand(),
+	}
+	if len(h.Alignment.Ops) > 0 {
+		jh.CIGAR = CIGAR(h.Alignment, len([]rune(h.Query)))
+	}
+	return j.enc.Encode(jh)
+}
+
+// Flush writes any buffered lines
+func (j *JSONWriter) Flush() error {
+	return j.w.Flush()
+}
@@ -0,0 +1,135 @@
+package seqio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/sstadick/fuzzyfind/approx"
+)
+
+// A Reference is a sequence that queries were aligned to, listed in the SAM header
+type Reference struct {
+	Name   string
+	Length int
+}
+
+// A Hit is a query, like a primer or a probe, aligned to a reference. Query is
+// the query as it was searched for, so the reverse complement for a hit on the
+// reverse strand, which is what SAM expects. Qual may be empty.
+type Hit struct {
+	QueryName string
+	Query     string
+	Qual      string
+	Ref       string
+	Alignment approx.Alignment
+	Reverse   bool
+}
+
+// SAMWriter writes hits as SAM records that samtools and genome browsers like IGV
+// can read. Convert the output with samtools to get BAM.
+type SAMWriter struct {
+	w *bufio.Writer
+}
+
+// NewSAMWriter writes the SAM header, with an @SQ line for each reference, and
+// returns a SAMWriter for the records
+func NewSAMWriter(w io.Writer, refs []Reference) (*SAMWriter, error) {
+	s := &SAMWriter{w: bufio.NewWriter(w)}
+	fmt.Fprintf(s.w, "@HD\tVN:1.6\tSO:unsorted\n")
+	for _, ref := range refs {
+		fmt.Fprintf(s.w, "@SQ\tSN:%s\tLN:%d\n", ref.Name, ref.Length)
+	}
+	fmt.Fprintf(s.w, "@PG\tID:fuzzyfind\tPN:fuzzyfind\n")
+	return s, s.w.Flush()
+}
+
+// Write writes a hit as a SAM record. POS is the 1-based start of the match, the
+// CIGAR comes from the alignment's edits with the unaligned ends of the query
+// soft clipped, and the NM tag is the number of edits. Hits without edits, like
+// those from MatchHits, have no CIGAR and return an error.
+func (s *SAMWriter) Write(h Hit) error {
+	if len(h.Alignment.Ops) == 0 {
+		return fmt.Errorf("hit of %s has no alignment to write as SAM", h.QueryName)
+	}
+	flag := 0
+	if h.Reverse {
+		flag |= 16
+	}
+	seq, qual := h.Query, h.Qual
+	if seq == "" {
+		seq = "*"
+	}
+	if qual == "" {
+		qual = "*"
+	}
+	cigar := CIGAR(h.Alignment, len([]rune(h.Query)))
+	_, err := fmt.Fprintf(s.w, "%s\t%d\t%s\t%d\t255\t%s\t*\t0\t0\t%s\t%s\tNM:i:%d\n",
+		h.QueryName, flag, h.Ref, h.Alignment.Start+1, cigar, seq, qual, editCount(h.Alignment.Ops))
+	return err
+}
+
+// editCount is the edit distance SAM's NM tag wants, whatever the costs of the
+// edits were. A transposition is two mismatched bases.
+func editCount(ops []approx.EditOp) int {
+	n := 0
+	for _, op := range ops {
+		switch op {
+		case approx.OpSub, approx.OpIns, approx.OpDel:
+			n++
+		case approx.OpTrans:
+			n += 2
+		}
+	}
+	return n
+}
+
+// Flush writes any buffered records
+func (s *SAMWriter) Flush() error {
+	return s.w.Flush()
+}
+
+// CIGAR turns an alignment of a query of queryLen runes into a SAM CIGAR string.
+// The query is the pattern, so an approx.OpDel, a pattern rune missing from the
+// text, is an I in the CIGAR and an approx.OpIns is a D. Matches, substitutions,
+// and transpositions are all M, and the parts of the query outside PatternStart
+// and PatternEnd are soft clipped. A queryLen of 0 skips the clipping at the end.
+func CIGAR(a approx.Alignment, queryLen int) string {
+	var cigar strings.Builder
+	var last byte
+	run := 0
+	add := func(op byte, n int) {
+		if op == last {
+			run += n
+			return
+		}
+		if run > 0 {
+			cigar.WriteString(strconv.Itoa(run))
+			cigar.WriteByte(last)
+		}
+		last, run = op, n
+	}
+	add('S', a.PatternStart)
+	for _, op := range a.Ops {
+		switch op {
+		case approx.OpMatch, approx.OpSub:
+			add('M', 1)
+		case approx.OpTrans:
+			add('M', 2)
+		case approx.OpDel:
+			add('I', 1)
+		case approx.OpIns:
+			add('D', 1)
+		}
+	}
+	if queryLen > a.PatternEnd {
+		add('S', queryLen-a.PatternEnd)
+	}
+	add(0, 0)
+	if cigar.Len() == 0 {
+		return "*"
+	}
+	return cigar.String()
+}
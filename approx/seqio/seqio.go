@@ -0,0 +1,305 @@
+// Package seqio reads FASTA and FASTQ files, plain or gzipped, and runs approx
+// searches over every record in them.
+package seqio
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sstadick/fuzzyfind/approx"
+)
+
+// A Record is one sequence of a FASTA or FASTQ file. Name is the header up to the
+// first space and Desc is the rest of it. Qual is the quality line of a FASTQ
+// record as it appears in the file, and empty for FASTA.
+type Record struct {
+	Name string
+	Desc string
+	Seq  string
+	Qual string
+}
+
+// ByteOffset returns the byte offset in s of the rune at offset i, the kind of
+// offset approx matches have, or len(s) if s has no more than i runes
+func ByteOffset(s string, i int) int {
+	for at := range s {
+		if i == 0 {
+			return at
+		}
+		i--
+	}
+	return len(s)
+}
+
+// Slice returns the record cut to runes from to to of its sequence, like the
+// Start and End of an approx match of it. Qual has a byte for each byte of Seq
+// and is cut at the same bytes.
+func (r Record) Slice(from int, to int) Record {
+	from, to = ByteOffset(r.Seq, from), ByteOffset(r.Seq, to)
+	r.Seq = r.Seq[from:to]
+	if r.Qual != "" {
+		r.Qual = r.Qual[from:to]
+	}
+	return r
+}
+
+// A Reader reads records one at a time, returning io.EOF after the last one
+type Reader interface {
+	Read() (Record, error)
+}
+
+// FastaReader reads FASTA records, which may have their sequence split over
+// several lines
+type FastaReader struct {
+	r      *bufio.Reader
+	header string
+	line   int
+}
+
+// NewFastaReader returns a FastaReader reading from r
+func NewFastaReader(r io.Reader) *FastaReader {
+	return &FastaReader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next record
+func (f *FastaReader) Read() (Record, error) {
+	if f.header == "" {
+		// Find the first header, skipping blank lines
+		for {
+			line, err := readLine(f.r)
+			if err != nil {
+				return Record{}, err
+			}
+			f.line++
+			if line == "" {
+				continue
+			}
+			if line[0] != '>' {
+				return Record{}, fmt.Errorf("fasta line %d: expected a '>' header, got %q", f.line, line)
+			}
+			f.header = line
+			break
+		}
+	}
+
+	rec := Record{}
+	rec.Name, rec.Desc = splitHeader(f.header[1:])
+	var seq strings.Builder
+	for {
+		line, err := readLine(f.r)
+		if err == io.EOF {
+			f.header = ""
+			break
+		} else if err != nil {
+			return Record{}, err
+		}
+		f.line++
+		if strings.HasPrefix(line, ">") {
+			f.header = line
+			break
+		}
+		seq.WriteString(strings.TrimSpace(line))
+	}
+	rec.Seq = seq.String()
+	return rec, nil
+}
+
+// FastqReader reads FASTQ records of four lines each
+type FastqReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+// NewFastqReader returns a FastqReader reading from r
+func NewFastqReader(r io.Reader) *FastqReader {
+	return &FastqReader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next record
+func (f *FastqReader) Read() (Record, error) {
+	var header string
+	for header == "" {
+		line, err := readLine(f.r)
+		if err != nil {
+			return Record{}, err
+		}
+		f.line++
+		header = line
+	}
+	if header[0] != '@' {
+		return Record{}, fmt.Errorf("fastq line %d: expected an '@' header, got %q", f.line, header)
+	}
+	lines := [3]string{}
+	for i := range lines {
+		line, err := readLine(f.r)
+		if err == io.EOF {
+			return Record{}, fmt.Errorf("fastq line %d: record %s is cut short", f.line, header)
+		} else if err != nil {
+			return Record{}, err
+		}
+		f.line++
+		lines[i] = line
+	}
+	if !strings.HasPrefix(lines[1], "+") {
+		return Record{}, fmt.Errorf("fastq line %d: expected a '+' separator, got %q", f.line-1, lines[1])
+	} else if len(lines[0]) != len(lines[2]) {
+		return Record{}, fmt.Errorf("fastq line %d: %d bases but %d qualities", f.line, len(lines[0]), len(lines[2]))
+	}
+	rec := Record{Seq: lines[0], Qual: lines[2]}
+	rec.Name, rec.Desc = splitHeader(header[1:])
+	return rec, nil
+}
+
+// FastqWriter writes records as four line FASTQ
+type FastqWriter struct {
+	w *bufio.Writer
+}
+
+// NewFastqWriter returns a FastqWriter writing to w
+func NewFastqWriter(w io.Writer) *FastqWriter {
+	return &FastqWriter{w: bufio.NewWriter(w)}
+}
+
+// Write writes a record. Records without qualities, like those read from FASTA,
+// get 'I' (Q40) for every base.
+func (f *FastqWriter) Write(rec Record) error {
+	qual := rec.Qual
+	if qual == "" {
+		qual = strings.Repeat("I", len(rec.Seq))
+	} else if len(qual) != len(rec.Seq) {
+		return fmt.Errorf("record %s has %d bases but %d qualities", rec.Name, len(rec.Seq), len(qual))
+	}
+	header := rec.Name
+	if rec.Desc != "" {
+		header += " " + rec.Desc
+	}
+	_, err := fmt.Fprintf(f.w, "@%s\n%s\n+\n%s\n", header, rec.Seq, qual)
+	return err
+}
+
+// Flush writes any buffered records
+func (f *FastqWriter) Flush() error {
+	return f.w.Flush()
+}
+
+// readLine reads a line without its line ending. The last line of the input
+// doesn't need one, io.EOF is only returned once there is nothing left.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// splitHeader splits a header line into the name and the description
+func splitHeader(header string) (string, string) {
+	if i := strings.IndexAny(header, " \t"); i >= 0 {
+		return header[:i], strings.TrimSpace(header[i+1:])
+	}
+	return header, ""
+}
+
+// NewReader returns a Reader for r, which may be gzipped, choosing FASTA or FASTQ
+// from its first character
+func NewReader(r io.Reader) (Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(gz)
+	}
+	for {
+		first, err := br.Peek(1)
+		if err == io.EOF {
+			// An empty file has no records either way
+			return NewFastaReader(br), nil
+		} else if err != nil {
+			return nil, err
+		}
+		switch first[0] {
+		case '>':
+			return NewFastaReader(br), nil
+		case '@':
+			return NewFastqReader(br), nil
+		case '\n', '\r':
+			br.ReadByte()
+			continue
+		}
+		return nil, fmt.Errorf("input is neither FASTA nor FASTQ, it starts with %q", first[0])
+	}
+}
+
+// A File is a Reader reading an open file
+type File struct {
+	Reader
+	f *os.File
+}
+
+// Open opens a FASTA or FASTQ file, gzipped or not, with "-" for standard input
+func Open(name string) (*File, error) {
+	f := os.Stdin
+	if name != "-" {
+		var err error
+		if f, err = os.Open(name); err != nil {
+			return nil, err
+		}
+	}
+	r, err := NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return &File{Reader: r, f: f}, nil
+}
+
+// Close closes the file
+func (f *File) Close() error {
+	return f.f.Close()
+}
+
+// A Searcher finds matches in a sequence. approx.Finder is a Searcher, and
+// SearcherFunc makes one out of any other search.
+type Searcher interface {
+	Find(text string) ([]approx.Match, error)
+}
+
+// SearcherFunc is a function used as a Searcher
+type SearcherFunc func(text string) ([]approx.Match, error)
+
+// Find calls s(text)
+func (s SearcherFunc) Find(text string) ([]approx.Match, error) {
+	return s(text)
+}
+
+// Search runs s over the sequence of every record read from r and calls found
+// with each match, in the order of the records. Empty records are skipped. It
+// stops at the first error, including one returned by found.
+func Search(r Reader, s Searcher, found func(rec Record, m approx.Match) error) error {
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		} else if rec.Seq == "" {
+			continue
+		}
+		matches, err := s.Find(rec.Seq)
+		if err != nil {
+			return fmt.Errorf("%s: %v", rec.Name, err)
+		}
+		for _, m := range matches {
+			if err := found(rec, m); err != nil {
+				return err
+			}
+		}
+	}
+}
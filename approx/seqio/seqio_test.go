@@ -0,0 +1,261 @@
+package seqio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sstadick/fuzzyfind/approx"
+)
+
+func readAll(t *testing.T, r Reader) []Record {
+	records := []Record{}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return records
+		} else if err != nil {
+			t.Fatalf("Read returned an error: %v", err)
+		}
+		records = append(records, rec)
+	}
+}
+
+func TestFastaReader(t *testing.T) {
+	input := ">seq1 first one\nGATT\nACA\n\n>seq2\r\nCCCC\r\n>empty\n"
+	records := readAll(t, NewFastaReader(strings.NewReader(input)))
+	expected := []Record{
+		{Name: "seq1", Desc: "first one", Seq: "GATTACA"},
+		{Name: "seq2", Seq: "CCCC"},
+		{Name: "empty"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("Bad records: %v, expected %v", records, expected)
+	}
+	for i := range records {
+		if records[i] != expected[i] {
+			t.Errorf("Bad record: %v, expected %v", records[i], expected[i])
+		}
+	}
+
+	if _, err := NewFastaReader(strings.NewReader("GATTACA\n")).Read(); err == nil {
+		t.Errorf("Expected an error for a missing header")
+	}
+}
+
+func TestFastqReader(t *testing.T) {
+	input := "@read1 lane 1\nGATTACA\n+\nIIIII#I\n@read2\nAC\n+read2\nII"
+	records := readAll(t, NewFastqReader(strings.NewReader(input)))
+	expected := []Record{
+		{Name: "read1", Desc: "lane 1", Seq: "GATTACA", Qual: "IIIII#I"},
+		{Name: "read2", Seq: "AC", Qual: "II"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("Bad records: %v, expected %v", records, expected)
+	}
+	for i := range records {
+		if records[i] != expected[i] {
+			t.Errorf("Bad record: %v, expected %v", records[i], expected[i])
+		}
+	}
+
+	for _, bad := range []string{"read\nAC\n+\nII\n", "@read\nAC\n-\nII\n", "@read\nAC\n+\nI\n", "@read\nAC\n"} {
+		if _, err := NewFastqReader(strings.NewReader(bad)).Read(); err == nil {
+			t.Errorf("Expected an error reading %q", bad)
+		}
+	}
+}
+
+func TestFastqWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewFastqWriter(&out)
+	records := []Record{
+		{Name: "read1", Desc: "lane 1", Seq: "GATTACA", Qual: "IIIII#I"},
+		{Name: "fasta", Seq: "ACG"},
+		{Name: "empty"},
+	}
+	for _, rec := range records {
+		if err := w.Write(rec); err != nil {
+			t.Fatalf("Write returned an error: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush returned an error: %v", err)
+	}
+	expected := "@read1 lane 1\nGATTACA\n+\nIIIII#I\n@fasta\nACG\n+\nIII\n@empty\n\n+\n\n"
+	if out.String() != expected {
+		t.Errorf("Bad FASTQ:\n%q\nexpected\n%q", out.String(), expected)
+	}
+	if again := readAll(t, NewFastqReader(&out)); len(again) != 3 || again[0] != records[0] {
+		t.Errorf("Bad records reading the FASTQ back: %v", again)
+	}
+
+	if err := w.Write(Record{Name: "bad", Seq: "AC", Qual: "I"}); err == nil {
+		t.Errorf("Expected an error writing mismatched qualities")
+	}
+}
+
+func TestRecordSlice(t *testing.T) {
+	rec := Record{Name: "read", Seq: "AñCGT", Qual: "!#$%&'"}
+	cases := []struct {
+		From, To  int
+		Seq, Qual string
+	}{
+		{0, 5, "AñCGT", "!#$%&'"},
+		{1, 3, "ñC", "#$%"},
+		{2, 5, "CGT", "%&'"},
+		{5, 5, "", ""},
+	}
+	for _, c := range cases {
+		cut := rec.Slice(c.From, c.To)
+		if cut.Name != rec.Name || cut.Seq != c.Seq || cut.Qual != c.Qual {
+			t.Errorf("Bad slice %d to %d: %v, expected %s %s", c.From, c.To, cut, c.Seq, c.Qual)
+		}
+	}
+	if fasta := (Record{Seq: "ñAC"}).Slice(1, 2); fasta.Seq != "A" || fasta.Qual != "" {
+		t.Errorf("Bad slice without qualities: %v", fasta)
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte("@read1\nGATTACA\n+\nIIIIIII\n"))
+	w.Close()
+	r, err := NewReader(&gz)
+	if err != nil {
+		t.Fatalf("NewReader returned an error: %v", err)
+	}
+	if records := readAll(t, r); len(records) != 1 || records[0].Qual != "IIIIIII" {
+		t.Errorf("Bad records from gzipped FASTQ: %v", records)
+	}
+
+	r, err = NewReader(strings.NewReader(">seq\nACGT\n"))
+	if err != nil {
+		t.Fatalf("NewReader returned an error: %v", err)
+	}
+	if _, ok := r.(*FastaReader); !ok {
+		t.Errorf("Expected a FastaReader, got %T", r)
+	}
+	if _, err := NewReader(strings.NewReader("ACGT\n")); err == nil {
+		t.Errorf("Expected an error for input that is neither FASTA nor FASTQ")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	input := ">a\nxxGATTACAxx\n>b\nCCCCCCC\n>c\nGATCACA\n"
+	f, err := approx.Compile("GATTACA", 1, approx.DefaultOptions)
+	if err != nil {
+		t.Fatalf("Compile returned an error: %v", err)
+	}
+	best := SearcherFunc(func(text string) ([]approx.Match, error) {
+		matches, err := f.Find(text)
+		if err != nil {
+			return nil, err
+		}
+		return approx.ResolveOverlaps(matches, approx.NonOverlapping)
+	})
+	found := []string{}
+	err = Search(NewFastaReader(strings.NewReader(input)), best, func(rec Record, m approx.Match) error {
+		found = append(found, rec.Name+":"+rec.Seq[m.Start:m.End])
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Search returned an error: %v", err)
+	}
+	if strings.Join(found, ",") != "a:GATTACA,c:GATCACA" {
+		t.Errorf("Bad matches: %v", found)
+	}
+}
+
+func TestCIGAR(t *testing.T) {
+	cases := []struct {
+		Alignment approx.Alignment
+		QueryLen  int
+		Expected  string
+	}{
+		{approx.Alignment{PatternEnd: 4, Ops: []approx.EditOp("==X=")}, 4, "4M"},
+		{approx.Alignment{PatternEnd: 5, Ops: []approx.EditOp("==D==I=")}, 5, "2M1I2M1D1M"},
+		{approx.Alignment{PatternStart: 2, PatternEnd: 5, Ops: []approx.EditOp("=T")}, 7, "2S3M2S"},
+		{approx.Alignment{}, 0, "*"},
+	}
+	for _, c := range cases {
+		if cigar := CIGAR(c.Alignment, c.QueryLen); cigar != c.Expected {
+			t.Errorf("Bad CIGAR for %s: %s, expected %s", string(c.Alignment.Ops), cigar, c.Expected)
+		}
+	}
+}
+
+func TestSAMWriter(t *testing.T) {
+	var out bytes.Buffer
+	w, err := NewSAMWriter(&out, []Reference{{Name: "chr1", Length: 100}})
+	if err != nil {
+		t.Fatalf("NewSAMWriter returned an error: %v", err)
+	}
+	alignments, _ := approx.ApproxFindAlignments("GATTACA", "xxGATCACAxx", 1, approx.DefaultOptions)
+	hit := Hit{QueryName: "probe1", Query: "GATTACA", Ref: "chr1", Alignment: alignments[0], Reverse: true}
+	if err := w.Write(hit); err != nil {
+		t.Fatalf("Write returned an error: %v", err)
+	}
+	w.Flush()
+	expected := "@HD\tVN:1.6\tSO:unsorted\n@SQ\tSN:chr1\tLN:100\n@PG\tID:fuzzyfind\tPN:fuzzyfind\n" +
+		"probe1\t16\tchr1\t3\t255\t7M\t*\t0\t0\tGATTACA\t*\tNM:i:1\n"
+	if out.String() != expected {
+		t.Errorf("Bad SAM:\n%q\nexpected\n%q", out.String(), expected)
+	}
+
+	// NM counts edits, not their costs, and a transposition is two mismatches
+	op := approx.DefaultOptions
+	op.TransCost = 1
+	alignments, _ = approx.ApproxFindAlignments("GATTACA", "xxGTATACAxx", 1, op)
+	out.Reset()
+	if err := w.Write(Hit{QueryName: "probe2", Query: "GATTACA", Ref: "chr1", Alignment: alignments[0]}); err != nil {
+		t.Fatalf("Write returned an error: %v", err)
+	}
+	w.Flush()
+	if expected := "probe2\t0\tchr1\t3\t255\t7M\t*\t0\t0\tGATTACA\t*\tNM:i:2\n"; out.String() != expected {
+		t.Errorf("Bad SAM:\n%q\nexpected\n%q", out.String(), expected)
+	}
+
+	if err := w.Write(MatchHits("probe3", "GATT", "chr1", []approx.Match{{Start: 2, End: 6}})[0]); err == nil {
+		t.Errorf("Expected an error writing a hit without an alignment")
+	}
+}
+
+func TestHitWriters(t *testing.T) {
+	alignments, _ := approx.ApproxFindAlignments("GATTACA", "xxGATACAxx", 1, approx.DefaultOptions)
+	aligned := Hit{QueryName: "p1", Query: "GATTACA", Ref: "chr1", Alignment: alignments[len(alignments)-1]}
+	hits := append([]Hit{aligned}, MatchHits("p;2", "CCC", "chr2", []approx.Match{{Start: 5, End: 8, Dist: 0}})...)
+	hits[1].Reverse = true
+
+	cases := []struct {
+		Name     string
+		New      func(w io.Writer) HitWriter
+		Expected string
+	}{
+		{"BED", func(w io.Writer) HitWriter { return NewBEDWriter(w) },
+			"chr1\t2\t8\tp1\t1\t+\nchr2\t5\t8\tp;2\t0\t-\n"},
+		{"GFF", func(w io.Writer) HitWriter { g, _ := NewGFFWriter(w, ""); return g },
+			"##gff-version 3\n" +
+				"chr1\tfuzzyfind\tmatch\t3\t8\t1\t+\t.\tID=match1;Name=p1;Gap=M2 I1 M4\n" +
+				"chr2\tfuzzyfind\tmatch\t6\t8\t0\t-\t.\tID=match2;Name=p%3B2\n"},
+		{"JSON", func(w io.Writer) HitWriter { return NewJSONWriter(w) },
+			`{"pattern":"GATTACA","pattern_id":"p1","seq":"chr1","start":2,"end":8,"dist":1,"strand":"+","cigar":"2M1I4M"}` + "\n" +
+				`{"pattern":"CCC","pattern_id":"p;2","seq":"chr2","start":5,"end":8,"dist":0,"strand":"-"}` + "\n"},
+	}
+	for _, c := range cases {
+		var out bytes.Buffer
+		w := c.New(&out)
+		for _, h := range hits {
+			if err := w.Write(h); err != nil {
+				t.Fatalf("%s Write returned an error: %v", c.Name, err)
+			}
+		}
+		w.Flush()
+		if out.String() != c.Expected {
+			t.Errorf("Bad %s:\n%q\nexpected\n%q", c.Name, out.String(), c.Expected)
+		}
+	}
+}
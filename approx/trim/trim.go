@@ -0,0 +1,193 @@
+// Package trim removes adapter sequences from reads, with semantics like
+// cutadapt's: the errors allowed in an adapter match grow with its length, and
+// adapters can be only partly there at the end of a read.
+package trim
+
+import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"github.com/sstadick/fuzzyfind/approx"
+	"github.com/sstadick/fuzzyfind/approx/seqio"
+)
+
+// Kind is where an adapter is found in a read and what is trimmed with it
+type Kind int
+
+const (
+	// ThreePrime adapters are found anywhere in the read, or with only their
+	// start hanging off its 3' end. The adapter and everything after it is
+	// trimmed.
+	ThreePrime Kind = iota
+	// FivePrime adapters are found anywhere in the read, or with only their end
+	// hanging off its 5' start. The adapter and everything before it is trimmed.
+	FivePrime
+	// AnchoredThreePrime adapters must be whole and end the read
+	AnchoredThreePrime
+	// AnchoredFivePrime adapters must be whole and start the read
+	AnchoredFivePrime
+)
+
+// An Adapter is a sequence to trim from reads
+type Adapter struct {
+	Name string
+	Seq  string
+	Kind Kind
+}
+
+// Options are the options for a Trimmer
+type Options struct {
+	// ErrorRate is the errors allowed per aligned adapter base. An overlap of
+	// n bases may have up to floor(n * ErrorRate) errors.
+	ErrorRate float64
+	// MinOverlap is the fewest adapter bases that must align for a partial
+	// adapter at the end of a read to be trimmed
+	MinOverlap int
+	// Match gives the costs and how bases are compared
+	Match approx.Options
+}
+
+// DefaultOptions are cutadapt's defaults: a 10% error rate and an overlap of at
+// least 3 bases, with unit costs
+var DefaultOptions Options = Options{
+	ErrorRate:  0.1,
+	MinOverlap: 3,
+	Match:      approx.DefaultOptions,
+}
+
+// A Result says which adapter, if any, was trimmed from a read
+type Result struct {
+	// Adapter is the adapter trimmed, nil if there was none
+	Adapter *Adapter
+	// Match is where the adapter was in the untrimmed read, with Dist being the
+	// cost of its errors, the number of them with unit costs
+	Match approx.Match
+	// Overlap is how many adapter bases were aligned
+	Overlap int
+}
+
+// A Trimmer trims adapters from reads
+type Trimmer struct {
+	adapters []Adapter
+	op       Options
+}
+
+// NewTrimmer checks the adapters and options and returns a Trimmer for them
+func NewTrimmer(adapters []Adapter, op Options) (*Trimmer, error) {
+	if len(adapters) == 0 {
+		return nil, fmt.Errorf("no adapters to trim")
+	} else if op.ErrorRate < 0 || op.ErrorRate >= 1 {
+		return nil, fmt.Errorf("error rate must be in [0, 1), got %v", op.ErrorRate)
+	} else if op.MinOverlap < 1 {
+		return nil, fmt.Errorf("minimum overlap must be at least 1, got %d", op.MinOverlap)
+	}
+	for _, a := range adapters {
+		if a.Seq == "" {
+			return nil, fmt.Errorf("adapter %q is empty", a.Name)
+		}
+	}
+	return &Trimmer{adapters: append([]Adapter{}, adapters...), op: op}, nil
+}
+
+// endGaps returns where an adapter of kind k may leave part of the read, or part
+// of itself, unaligned
+func (k Kind) endGaps() approx.EndGaps {
+	switch k {
+	case FivePrime:
+		return approx.FreePatternPrefix | approx.FreeTextPrefix | approx.FreeTextSuffix
+	case AnchoredThreePrime:
+		return approx.FreeTextPrefix
+	case AnchoredFivePrime:
+		return approx.FreeTextSuffix
+	}
+	return approx.FreePatternSuffix | approx.FreeTextPrefix | approx.FreeTextSuffix
+}
+
+// Find finds the best match of any adapter in seq. The best is the one with the
+// most matching bases, then the fewest errors, then the first adapter.
+func (t *Trimmer) Find(seq string) (Result, error) {
+	best := Result{}
+	bestMatches := 0
+	if seq == "" {
+		return best, nil
+	}
+	for i := range t.adapters {
+		a := &t.adapters[i]
+		r, matches, err := t.find(a, seq)
+		if err != nil {
+			return Result{}, fmt.Errorf("adapter %s: %v", a.Name, err)
+		}
+		if r.Adapter != nil && (best.Adapter == nil || matches > bestMatches ||
+			(matches == bestMatches && r.Match.Dist < best.Match.Dist)) {
+			best, bestMatches = r, matches
+		}
+	}
+	return best, nil
+}
+
+// find finds the best match of one adapter, and how many of its bases match. The
+// error rate is enforced while searching, so a partial adapter is judged by its
+// overlap with the read.
+func (t *Trimmer) find(a *Adapter, seq string) (Result, int, error) {
+	op := t.op.Match
+	op.EndGaps = a.Kind.endGaps()
+	op.MaxErrorRate = t.op.ErrorRate
+	adapterLen := len([]rune(a.Seq))
+	// The whole adapter is allowed the most errors, and none without a rate
+	maxE := int(math.Floor(float64(adapterLen) * t.op.ErrorRate))
+	alignments, err := approx.ApproxFindAlignments(a.Seq, seq, maxE, op)
+	if err != nil {
+		return Result{}, 0, err
+	}
+
+	best := Result{}
+	bestMatches := 0
+	for _, al := range alignments {
+		overlap := al.PatternEnd - al.PatternStart
+		if overlap < adapterLen && overlap < t.op.MinOverlap {
+			continue
+		}
+		matches := 0
+		for _, o := range al.Ops {
+			if o == approx.OpMatch {
+				matches++
+			}
+		}
+		r := Result{Adapter: a, Match: al.Match, Overlap: overlap}
+		if best.Adapter == nil || matches > bestMatches || (matches == bestMatches && t.before(r, best)) {
+			best, bestMatches = r, matches
+		}
+	}
+	return best, bestMatches, nil
+}
+
+// before reports whether r should be preferred to best when both have as many
+// matching bases: fewer errors, then the one that trims more of the read
+func (t *Trimmer) before(r Result, best Result) bool {
+	if r.Match.Dist != best.Match.Dist {
+		return r.Match.Dist < best.Match.Dist
+	}
+	switch r.Adapter.Kind {
+	case FivePrime, AnchoredFivePrime:
+		return r.Match.End > best.Match.End
+	}
+	return r.Match.Start < best.Match.Start
+}
+
+// Trim removes the best adapter match from a read, along with everything after a
+// 3' adapter or before a 5' adapter. The qualities are trimmed to match. Reads
+// without an adapter are returned as they are.
+func (t *Trimmer) Trim(rec seqio.Record) (seqio.Record, Result, error) {
+	r, err := t.Find(rec.Seq)
+	if err != nil || r.Adapter == nil {
+		return rec, r, err
+	}
+	switch r.Adapter.Kind {
+	case ThreePrime, AnchoredThreePrime:
+		rec = rec.Slice(0, r.Match.Start)
+	default:
+		rec = rec.Slice(r.Match.End, utf8.RuneCountInString(rec.Seq))
+	}
+	return rec, r, nil
+}
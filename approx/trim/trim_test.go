@@ -0,0 +1,131 @@
+package trim
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sstadick/fuzzyfind/approx/seqio"
+)
+
+func TestTrim(t *testing.T) {
+	const adapter = "AGATCGGAAG"
+	cases := []struct {
+		Adapter  Adapter
+		Seq      string
+		Expected string
+		Dist     int
+	}{
+		// Whole 3' adapter, with and without an error, and a partial one
+		{Adapter{"a", adapter, ThreePrime}, "CCCCCCAGATCGGAAGTTTT", "CCCCCC", 0},
+		{Adapter{"a", adapter, ThreePrime}, "CCCCCCAGATCGCAAGTTTT", "CCCCCC", 1},
+		{Adapter{"a", adapter, ThreePrime}, "CCCCCCCCCCAGATC", "CCCCCCCCCC", 0},
+		// Too short an overlap, and too many errors for one
+		{Adapter{"a", adapter, ThreePrime}, "CCCCCCCCCCCCAG", "CCCCCCCCCCCCAG", 0},
+		{Adapter{"a", adapter, ThreePrime}, "CCCCCCCCCCAGTTCG", "CCCCCCCCCCAGTTCG", 0},
+		{Adapter{"a", adapter, ThreePrime}, "CCCCCCTTTTTTTTTT", "CCCCCCTTTTTTTTTT", 0},
+		// 5' adapters, whole and hanging off the start
+		{Adapter{"g", adapter, FivePrime}, "TTAGATCGGAAGCCCCCC", "CCCCCC", 0},
+		{Adapter{"g", adapter, FivePrime}, "CGGAAGCCCCCCCC", "CCCCCCCC", 0},
+		// Anchored adapters must be whole and at the end
+		{Adapter{"A", adapter, AnchoredThreePrime}, "CCCCCCAGATCGGAAG", "CCCCCC", 0},
+		{Adapter{"A", adapter, AnchoredThreePrime}, "CCCCCCAGATCGGAAGTT", "CCCCCCAGATCGGAAGTT", 0},
+		{Adapter{"A", adapter, AnchoredThreePrime}, "CCCCCCAGATC", "CCCCCCAGATC", 0},
+		{Adapter{"G", adapter, AnchoredFivePrime}, "AGATCGGAAGCCCCCC", "CCCCCC", 0},
+		{Adapter{"G", adapter, AnchoredFivePrime}, "TTAGATCGGAAGCCCCCC", "TTAGATCGGAAGCCCCCC", 0},
+	}
+	for _, c := range cases {
+		trimmer, err := NewTrimmer([]Adapter{c.Adapter}, DefaultOptions)
+		if err != nil {
+			t.Fatalf("NewTrimmer returned an error: %v", err)
+		}
+		qual := make([]byte, len(c.Seq))
+		for i := range qual {
+			qual[i] = byte('!' + i)
+		}
+		rec := seqio.Record{Name: "read", Seq: c.Seq, Qual: string(qual)}
+		trimmed, result, err := trimmer.Trim(rec)
+		if err != nil {
+			t.Errorf("Trim returned an error for %s in %s: %v", c.Adapter.Seq, c.Seq, err)
+			continue
+		}
+		if trimmed.Seq != c.Expected {
+			t.Errorf("Bad trim of %s with %s adapter %s: %s, expected %s", c.Seq, c.Adapter.Name, c.Adapter.Seq, trimmed.Seq, c.Expected)
+		}
+		// The kept bases are at the end of the read for a 5' adapter
+		from := 0
+		if c.Adapter.Kind == FivePrime || c.Adapter.Kind == AnchoredFivePrime {
+			from = len(c.Seq) - len(trimmed.Seq)
+		}
+		if trimmed.Qual != string(qual[from:from+len(trimmed.Seq)]) {
+			t.Errorf("Bad qualities for %s: %q", c.Seq, trimmed.Qual)
+		}
+		if trimmed.Seq != c.Seq && result.Match.Dist != c.Dist {
+			t.Errorf("Bad errors for %s in %s: %d, expected %d", c.Adapter.Seq, c.Seq, result.Match.Dist, c.Dist)
+		}
+	}
+}
+
+func TestTrimOffsets(t *testing.T) {
+	cases := []struct {
+		Kind     Kind
+		Seq      string
+		Expected string
+	}{
+		{ThreePrime, "ñCCCCCAGATCGGAAGTT", "ñCCCCC"},
+		{FivePrime, "ñAGATCGGAAGCCCCCC", "CCCCCC"},
+	}
+	for _, c := range cases {
+		trimmer, err := NewTrimmer([]Adapter{{"a", "AGATCGGAAG", c.Kind}}, DefaultOptions)
+		if err != nil {
+			t.Fatalf("NewTrimmer returned an error: %v", err)
+		}
+		// One quality for each byte, as a FASTQ reader checks
+		qual := make([]byte, len(c.Seq))
+		for i := range qual {
+			qual[i] = byte('!' + i)
+		}
+		trimmed, _, err := trimmer.Trim(seqio.Record{Name: "read", Seq: c.Seq, Qual: string(qual)})
+		if err != nil {
+			t.Fatalf("Trim returned an error for %s: %v", c.Seq, err)
+		}
+		from := strings.Index(c.Seq, c.Expected)
+		if trimmed.Seq != c.Expected || trimmed.Qual != string(qual[from:from+len(c.Expected)]) {
+			t.Errorf("Bad trim of %s: %s %q, expected %s", c.Seq, trimmed.Seq, trimmed.Qual, c.Expected)
+		}
+	}
+}
+
+func TestTrimBestAdapter(t *testing.T) {
+	adapters := []Adapter{{"short", "AGATC", ThreePrime}, {"long", "AGATCGGAAG", ThreePrime}}
+	trimmer, err := NewTrimmer(adapters, DefaultOptions)
+	if err != nil {
+		t.Fatalf("NewTrimmer returned an error: %v", err)
+	}
+	trimmed, result, err := trimmer.Trim(seqio.Record{Seq: "CCCCAGATCGGAAGTT"})
+	if err != nil {
+		t.Fatalf("Trim returned an error: %v", err)
+	}
+	if result.Adapter == nil || result.Adapter.Name != "long" || trimmed.Seq != "CCCC" {
+		t.Errorf("Bad trim: %v %v, expected the long adapter to leave CCCC", trimmed, result)
+	}
+}
+
+func TestNewTrimmerErrors(t *testing.T) {
+	adapters := []Adapter{{"a", "ACGT", ThreePrime}}
+	bad := []Options{
+		{ErrorRate: -0.1, MinOverlap: 3},
+		{ErrorRate: 1, MinOverlap: 3},
+		{ErrorRate: 0.1, MinOverlap: 0},
+	}
+	for _, op := range bad {
+		if _, err := NewTrimmer(adapters, op); err == nil {
+			t.Errorf("Expected an error for %+v", op)
+		}
+	}
+	if _, err := NewTrimmer(nil, DefaultOptions); err == nil {
+		t.Errorf("Expected an error for no adapters")
+	}
+	if _, err := NewTrimmer([]Adapter{{"empty", "", ThreePrime}}, DefaultOptions); err == nil {
+		t.Errorf("Expected an error for an empty adapter")
+	}
+}
@@ -0,0 +1,204 @@
+// Command fuzzyfind is agrep for sequences: it finds approximate matches of one
+// or more patterns in every line of its input and prints them as TSV.
+//
+//	fuzzyfind [flags] PATTERN [FILE...]
+//	fuzzyfind [flags] -f PATTERNS [FILE...]
+//	fuzzyfind trim [flags] -a ADAPTER [FILE...]
+//
+// With no FILE, or when FILE is -, standard input is read. Each match is printed
+// as file, line number, pattern, start, end, distance, and the matched text, with
+// start and end being rune offsets in the line. Like grep, the exit status is 0 if
+// anything matched, 1 if nothing did, and 2 if there was an error, in which case
+// the files after the one with the error are still searched.
+//
+// The trim subcommand removes adapters from the reads of FASTA or FASTQ files and
+// writes the trimmed reads as FASTQ, with a summary on standard error.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sstadick/fuzzyfind/approx"
+)
+
+// Exit statuses, the same as grep's
+const (
+	exitMatch   = 0
+	exitNoMatch = 1
+	exitError   = 2
+)
+
+// ANSI escapes used to highlight matches
+const (
+	colorStart = "\x1b[1;31m"
+	colorEnd   = "\x1b[0m"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command line in args and returns the exit status
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "trim" {
+		return runTrim(args[1:], stdin, stdout, stderr)
+	}
+	flags := flag.NewFlagSet("fuzzyfind", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: fuzzyfind [flags] PATTERN [FILE...]\n       fuzzyfind [flags] -f PATTERNS [FILE...]\n\n")
+		flags.PrintDefaults()
+	}
+	patternFile := flags.String("f", "", "read patterns from this file, one per line")
+	maxE := flags.Int("k", 1, "the maximum edit distance")
+	insCost := flags.Int("ins", 1, "the cost of an insertion")
+	delCost := flags.Int("del", 1, "the cost of a deletion")
+	subCost := flags.Int("sub", 1, "the cost of a substitution")
+	transCost := flags.Int("trans", 0, "the cost of swapping two adjacent runes, 0 to not allow it")
+	ignoreCase := flags.Bool("i", false, "ignore case")
+	all := flags.Bool("all", false, "print every match, not just the best non-overlapping ones")
+	context := flags.Int("context", -1, "print this many runes around each match, with the match highlighted")
+	color := flags.Bool("color", false, "highlight with ANSI colors instead of [brackets]")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitMatch
+		}
+		return exitError
+	}
+
+	rest := flags.Args()
+	patterns := []string{}
+	if *patternFile != "" {
+		var err error
+		if patterns, err = readPatterns(*patternFile); err != nil {
+			fmt.Fprintf(stderr, "fuzzyfind: %v\n", err)
+			return exitError
+		}
+	} else if len(rest) > 0 {
+		patterns = append(patterns, rest[0])
+		rest = rest[1:]
+	}
+	if len(patterns) == 0 {
+		flags.Usage()
+		return exitError
+	}
+	if len(rest) == 0 {
+		rest = []string{"-"}
+	}
+
+	op := approx.DefaultOptions
+	if *ignoreCase {
+		op = approx.CaseInsensitiveOptions
+	}
+	op.InsCost, op.DelCost, op.SubCost, op.TransCost = *insCost, *delCost, *subCost, *transCost
+	policy := approx.NonOverlapping
+	if *all {
+		policy = approx.KeepAll
+	}
+	p := &printer{out: bufio.NewWriter(stdout), context: *context, color: *color}
+	defer p.out.Flush()
+
+	// Like grep, an error with one file doesn't stop the others from being
+	// searched, but the exit status says there was one
+	status, failed := exitNoMatch, false
+	for _, name := range rest {
+		found, err := searchFile(name, stdin, patterns, *maxE, op, policy, p)
+		if err != nil {
+			fmt.Fprintf(stderr, "fuzzyfind: %v\n", err)
+			failed = true
+		}
+		if found {
+			status = exitMatch
+		}
+	}
+	if failed {
+		return exitError
+	}
+	return status
+}
+
+// readPatterns reads the non-empty lines of a file
+func readPatterns(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	patterns := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+// searchFile searches every line of a file, or of stdin for "-", for every
+// pattern and reports whether anything was found
+func searchFile(name string, stdin io.Reader, patterns []string, maxE int, op approx.Options, policy approx.OverlapPolicy, p *printer) (bool, error) {
+	in := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		in = f
+	}
+	found := false
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		for _, pattern := range patterns {
+			matches, err := approx.ApproxFind(pattern, line, maxE, op)
+			if err != nil {
+				return found, err
+			}
+			if matches, err = approx.ResolveOverlaps(matches, policy); err != nil {
+				return found, err
+			}
+			for _, m := range matches {
+				p.print(name, lineNo, pattern, []rune(line), m)
+				found = true
+			}
+		}
+	}
+	return found, scanner.Err()
+}
+
+// printer writes matches as TSV
+type printer struct {
+	out     *bufio.Writer
+	context int
+	color   bool
+}
+
+func (p *printer) print(name string, lineNo int, pattern string, line []rune, m approx.Match) {
+	fmt.Fprintf(p.out, "%s\t%d\t%s\t%d\t%d\t%d\t%s", name, lineNo, pattern, m.Start, m.End, m.Dist, string(line[m.Start:m.End]))
+	if p.context >= 0 {
+		from := m.Start - p.context
+		if from < 0 {
+			from = 0
+		}
+		to := m.End + p.context
+		if to > len(line) {
+			to = len(line)
+		}
+		before, after := "[", "]"
+		if p.color {
+			before, after = colorStart, colorEnd
+		}
+		fmt.Fprintf(p.out, "\t%s%s%s%s%s", string(line[from:m.Start]), before, string(line[m.Start:m.End]), after, string(line[m.End:to]))
+	}
+	fmt.Fprintln(p.out)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	input := "xxGATTACAxx\nnothing here\nGATCACA\n"
+	cases := []struct {
+		Args     []string
+		Status   int
+		Expected string
+	}{
+		{[]string{"-k", "1", "GATTACA"}, exitMatch,
+			"-\t1\tGATTACA\t2\t9\t0\tGATTACA\n-\t3\tGATTACA\t0\t7\t1\tGATCACA\n"},
+		{[]string{"-k", "0", "-context", "1", "GATTACA"}, exitMatch,
+			"-\t1\tGATTACA\t2\t9\t0\tGATTACA\tx[GATTACA]x\n"},
+		{[]string{"-k", "0", "-i", "gattaca"}, exitMatch,
+			"-\t1\tgattaca\t2\t9\t0\tGATTACA\n"},
+		{[]string{"-k", "0", "CCCCCC"}, exitNoMatch, ""},
+		{[]string{}, exitError, ""},
+		{[]string{"GATTACA", "no-such-file"}, exitError, ""},
+		// The files after one that can't be read are still searched
+		{[]string{"-k", "0", "GATTACA", "no-such-file", "-"}, exitError,
+			"-\t1\tGATTACA\t2\t9\t0\tGATTACA\n"},
+	}
+	for _, c := range cases {
+		var stdout, stderr bytes.Buffer
+		status := run(c.Args, strings.NewReader(input), &stdout, &stderr)
+		if status != c.Status {
+			t.Errorf("Bad exit status for %v: %d, expected %d (%s)", c.Args, status, c.Status, stderr.String())
+		}
+		if stdout.String() != c.Expected {
+			t.Errorf("Bad output for %v:\n%q\nexpected\n%q", c.Args, stdout.String(), c.Expected)
+		}
+	}
+}
+
+func TestRunTrim(t *testing.T) {
+	input := "@r1\nCCCCCCAGATCGGAAGTTTT\n+\nIIIIIIIIIIIIIIIIIIII\n@r2\nCCCCCCCCCC\n+\nIIIIIIIIII\n"
+	cases := []struct {
+		Args     []string
+		Status   int
+		Expected string
+		Summary  string
+	}{
+		{[]string{"trim", "-a", "ad=AGATCGGAAG"}, exitMatch,
+			"@r1\nCCCCCC\n+\nIIIIII\n@r2\nCCCCCCCCCC\n+\nIIIIIIIIII\n",
+			"reads processed\t2\nreads with adapters\t1\nadapter ad\t1\n"},
+		{[]string{"trim", "-a", "AGATCGGAAG$"}, exitMatch,
+			"@r1\nCCCCCCAGATCGGAAGTTTT\n+\nIIIIIIIIIIIIIIIIIIII\n@r2\nCCCCCCCCCC\n+\nIIIIIIIIII\n",
+			"reads processed\t2\nreads with adapters\t0\nadapter 1\t0\n"},
+		{[]string{"trim"}, exitError, "", ""},
+		{[]string{"trim", "-a", "ACGT", "-e", "1.5"}, exitError, "", ""},
+	}
+	for _, c := range cases {
+		var stdout, stderr bytes.Buffer
+		status := run(c.Args, strings.NewReader(input), &stdout, &stderr)
+		if status != c.Status {
+			t.Errorf("Bad exit status for %v: %d, expected %d (%s)", c.Args, status, c.Status, stderr.String())
+		}
+		if stdout.String() != c.Expected {
+			t.Errorf("Bad output for %v:\n%q\nexpected\n%q", c.Args, stdout.String(), c.Expected)
+		}
+		if c.Status == exitMatch && stderr.String() != c.Summary {
+			t.Errorf("Bad summary for %v:\n%q\nexpected\n%q", c.Args, stderr.String(), c.Summary)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sstadick/fuzzyfind/approx/seqio"
+	"github.com/sstadick/fuzzyfind/approx/trim"
+)
+
+// adapterFlags collects the adapters given by repeating a flag
+type adapterFlags struct {
+	kind     trim.Kind
+	adapters *[]trim.Adapter
+}
+
+func (a adapterFlags) String() string {
+	return ""
+}
+
+// Set parses an adapter like cutadapt does: an optional NAME= prefix, and the
+// sequence ending in $ for an anchored 3' adapter or starting with ^ for an
+// anchored 5' one
+func (a adapterFlags) Set(value string) error {
+	adapter := trim.Adapter{Kind: a.kind}
+	if i := strings.IndexByte(value, '='); i >= 0 {
+		adapter.Name, value = value[:i], value[i+1:]
+	}
+	switch {
+	case a.kind == trim.ThreePrime && strings.HasSuffix(value, "$"):
+		adapter.Kind, value = trim.AnchoredThreePrime, strings.TrimSuffix(value, "$")
+	case a.kind == trim.FivePrime && strings.HasPrefix(value, "^"):
+		adapter.Kind, value = trim.AnchoredFivePrime, strings.TrimPrefix(value, "^")
+	}
+	if value == "" {
+		return fmt.Errorf("empty adapter")
+	}
+	adapter.Seq = strings.ToUpper(value)
+	if adapter.Name == "" {
+		adapter.Name = fmt.Sprintf("%d", len(*a.adapters)+1)
+	}
+	*a.adapters = append(*a.adapters, adapter)
+	return nil
+}
+
+// runTrim runs the trim subcommand, which removes adapters from the reads of
+// FASTA or FASTQ files and writes them as FASTQ
+//
+//	fuzzyfind trim [flags] -a ADAPTER [-g ADAPTER...] [FILE...]
+func runTrim(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fuzzyfind trim", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: fuzzyfind trim [flags] -a ADAPTER [-g ADAPTER...] [FILE...]\n\n")
+		flags.PrintDefaults()
+	}
+	adapters := []trim.Adapter{}
+	flags.Var(adapterFlags{trim.ThreePrime, &adapters}, "a", "a 3' adapter, trimmed with everything after it; end it with $ to anchor it to the end of the read")
+	flags.Var(adapterFlags{trim.FivePrime, &adapters}, "g", "a 5' adapter, trimmed with everything before it; start it with ^ to anchor it to the start of the read")
+	errorRate := flags.Float64("e", trim.DefaultOptions.ErrorRate, "the errors allowed per aligned adapter base")
+	minOverlap := flags.Int("O", trim.DefaultOptions.MinOverlap, "the fewest bases of a partial adapter to trim")
+	output := flags.String("o", "-", "write the trimmed reads to this file")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitMatch
+		}
+		return exitError
+	}
+	if len(adapters) == 0 {
+		flags.Usage()
+		return exitError
+	}
+	op := trim.DefaultOptions
+	op.ErrorRate, op.MinOverlap = *errorRate, *minOverlap
+	trimmer, err := trim.NewTrimmer(adapters, op)
+	if err != nil {
+		fmt.Fprintf(stderr, "fuzzyfind: %v\n", err)
+		return exitError
+	}
+
+	out := stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(stderr, "fuzzyfind: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		out = f
+	}
+	w := seqio.NewFastqWriter(out)
+	stats := trimStats{trimmed: map[string]int{}}
+	names := flags.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	for _, name := range names {
+		if err := trimFile(name, stdin, trimmer, w, &stats); err != nil {
+			fmt.Fprintf(stderr, "fuzzyfind: %v\n", err)
+			return exitError
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(stderr, "fuzzyfind: %v\n", err)
+		return exitError
+	}
+	stats.print(stderr, adapters)
+	return exitMatch
+}
+
+// trimStats counts the reads processed and trimmed by each adapter
+type trimStats struct {
+	reads   int
+	trimmed map[string]int
+}
+
+// print writes a summary of the trimming
+func (s *trimStats) print(w io.Writer, adapters []trim.Adapter) {
+	total := 0
+	for _, n := range s.trimmed {
+		total += n
+	}
+	fmt.Fprintf(w, "reads processed\t%d\n", s.reads)
+	fmt.Fprintf(w, "reads with adapters\t%d\n", total)
+	for _, a := range adapters {
+		fmt.Fprintf(w, "adapter %s\t%d\n", a.Name, s.trimmed[a.Name])
+	}
+}
+
+// trimFile trims every read of a file, or of stdin for "-", and writes them to w
+func trimFile(name string, stdin io.Reader, trimmer *trim.Trimmer, w *seqio.FastqWriter, stats *trimStats) error {
+	var r seqio.Reader
+	if name == "-" {
+		var err error
+		if r, err = seqio.NewReader(stdin); err != nil {
+			return err
+		}
+	} else {
+		f, err := seqio.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		trimmed, result, err := trimmer.Trim(rec)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %v", name, rec.Name, err)
+		}
+		stats.reads++
+		if result.Adapter != nil {
+			stats.trimmed[result.Adapter.Name]++
+		}
+		if err := w.Write(trimmed); err != nil {
+			return err
+		}
+	}
+}